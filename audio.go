@@ -0,0 +1,93 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Get the ffmpeg output args of an audio format.
+func audioOutputArgs(format string) ([]string, error) {
+	switch format {
+	case "wav":
+		return []string{"-f", "wav"}, nil
+	case "mp3":
+		return []string{"-f", "mp3"}, nil
+	case "pcm_s16le":
+		// raw samples without a header
+		return []string{"-c:a", "pcm_s16le", "-f", "s16le"}, nil
+	}
+	return nil, fmt.Errorf("unknown audio format: %q", format)
+}
+
+// Build the ffmpeg args to read the input and print the audio to stdout.
+func (c *Client) audioArgs(input []string, format string) ([]string, error) {
+	output, err := audioOutputArgs(format)
+	if err != nil {
+		return nil, err
+	}
+	// see SetFfmpegLogLevel
+	args := []string{"-v", c.getFfmpegLogLevel()}
+	args = append(args, input...)
+	args = append(args, "-vn") // no video
+	args = append(args, output...)
+	return append(args, "-"), nil // print to stdout
+}
+
+// Extract the audio of a media.
+//
+// Args:
+//
+//	input: url or local path of the media
+//	format: "wav", "mp3" or "pcm_s16le"
+//
+// Returns:
+//
+//	[]byte: the encoded audio
+//	error: error
+func ExtractAudio(input string, format string) ([]byte, error) {
+	return defaultClient.ExtractAudio(input, format)
+}
+
+// Like ExtractAudio, using the client's settings.
+func (c *Client) ExtractAudio(input string, format string) ([]byte, error) {
+	args, err := c.audioArgs([]string{"-i", input}, format)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := c.RunFfmpeg(context.Background(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Extract the audio of a media stream like .ts read from r.
+//
+// Args:
+//
+//	r: reader of the stream
+//	format: "wav", "mp3" or "pcm_s16le"
+//
+// Returns:
+//
+//	[]byte: the encoded audio
+//	error: error
+func ReaderExtractAudio(r io.Reader, format string) ([]byte, error) {
+	return defaultClient.ReaderExtractAudio(r, format)
+}
+
+// Like ReaderExtractAudio, using the client's settings.
+func (c *Client) ReaderExtractAudio(
+	r io.Reader, format string) ([]byte, error) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.audioArgs([]string{"-i", "pipe:"}, format)
+	if err != nil {
+		return nil, err
+	}
+	// not timed out, the whole stream is encoded
+	return runFfmpegPipe(context.Background(), ffmpeg, r, args, 0)
+}
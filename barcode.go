@@ -0,0 +1,91 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/aztec"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/oned/rss"
+)
+
+var errFormatUnsupported = errors.New("unsupported barcode format")
+
+// Get a reader of a single barcode of the format.
+func newBarcodeReader(format gozxing.BarcodeFormat) (gozxing.Reader, error) {
+	switch format {
+	case gozxing.BarcodeFormat_AZTEC:
+		return aztec.NewAztecReader(), nil
+	case gozxing.BarcodeFormat_CODABAR:
+		return oned.NewCodaBarReader(), nil
+	case gozxing.BarcodeFormat_CODE_39:
+		return oned.NewCode39Reader(), nil
+	case gozxing.BarcodeFormat_CODE_93:
+		return oned.NewCode93Reader(), nil
+	case gozxing.BarcodeFormat_CODE_128:
+		return oned.NewCode128Reader(), nil
+	case gozxing.BarcodeFormat_DATA_MATRIX:
+		return datamatrix.NewDataMatrixReader(), nil
+	case gozxing.BarcodeFormat_EAN_8:
+		return oned.NewEAN8Reader(), nil
+	case gozxing.BarcodeFormat_EAN_13:
+		return oned.NewEAN13Reader(), nil
+	case gozxing.BarcodeFormat_ITF:
+		return oned.NewITFReader(), nil
+	case gozxing.BarcodeFormat_RSS_14:
+		return rss.NewRSS14Reader(), nil
+	case gozxing.BarcodeFormat_UPC_A:
+		return oned.NewUPCAReader(), nil
+	case gozxing.BarcodeFormat_UPC_E:
+		return oned.NewUPCEReader(), nil
+	}
+	return nil, fmt.Errorf("%w: %v", errFormatUnsupported, format)
+}
+
+// Scan barcodes of the formats in an image.
+//
+// QR codes may decode several per image, other formats at most one each.
+//
+// Args:
+//
+//	img: the image
+//	formats: formats to scan, or nil for QR codes only
+//
+// Returns:
+//
+//	[]QRResult: decoded codes
+//	error: error
+func ScanBarcodes(
+	img image.Image, formats []gozxing.BarcodeFormat) ([]QRResult, error) {
+	if len(formats) == 0 {
+		formats = []gozxing.BarcodeFormat{gozxing.BarcodeFormat_QR_CODE}
+	}
+	bmp, scale, err := newBitmap(img, defaultClient.getScanMaxDimension())
+	if err != nil {
+		return nil, err
+	}
+	var data []QRResult
+	for _, format := range formats {
+		if format == gozxing.BarcodeFormat_QR_CODE {
+			results, _ := qrReader.DecodeMultiple(bmp, nil)
+			for _, r := range results {
+				data = append(data, newQRResult(r, scale))
+			}
+			continue
+		}
+		reader, err := newBarcodeReader(format)
+		if err != nil {
+			return nil, err
+		}
+		if r, err := reader.Decode(bmp, nil); err == nil {
+			data = append(data, newQRResult(r, scale))
+		}
+	}
+	if len(data) == 0 {
+		return nil, gozxing.NewNotFoundException()
+	}
+	return data, nil
+}
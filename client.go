@@ -0,0 +1,250 @@
+package ffmpeghelper
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Client managing FFmpeg binaries and reading streams with its own settings.
+//
+// Create one with New. The package-level functions use a default client
+// configured by the SetXxx functions.
+type Client struct {
+	// guards the settings below
+	lock             sync.RWMutex
+	installDir       string
+	httpClient       *http.Client
+	downloadTimeout  time.Duration
+	streamTimeout    time.Duration
+	downloadProgress func(downloaded, total int64)
+	releaseBaseURL   string
+	downloadMirrors  []string
+	downloadAttempts int
+	logger           *slog.Logger
+	embeddedFS       fs.FS
+	variantSelector  func(variants []Variant) int
+	ffmpegLogLevel   string
+	fontFile         string
+	ffmpegBase       string
+	variantSep       string
+	frameCache       *frameCache
+	ffmpegThreads    int
+	searchOrder      SearchOrder
+	scanMaxDimension int
+
+	// resolved binaries
+	ffmpegPath   pathCache
+	ffprobePath  pathCache
+	resolveGroup singleflight.Group
+	// major versions of ffmpeg by path, guarded by lock
+	ffmpegMajors map[string]majorVersion
+}
+
+// Option of a Client.
+type Option func(c *Client)
+
+// Create a client with the options, the default settings are used for the
+// ones not given.
+//
+// Args:
+//
+//	opts: options like WithHTTPClient or WithInstallDir
+//
+// Returns:
+//
+//	*Client: the client
+func New(opts ...Option) *Client {
+	c := &Client{
+		httpClient:       defaultHTTPClient,
+		downloadTimeout:  defaultDownloadTimeout,
+		streamTimeout:    defaultStreamTimeout,
+		releaseBaseURL:   defaultReleaseBaseURL,
+		downloadMirrors:  defaultDownloadMirrors,
+		downloadAttempts: defaultDownloadAttempts,
+		variantSelector:  lowestBandwidth,
+		ffmpegLogLevel:   defaultFfmpegLogLevel,
+		variantSep:       defaultVariantSep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// client used by the package-level functions
+var defaultClient = New()
+
+// Apply an option to the client after its creation.
+func (c *Client) set(opt Option) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	opt(c)
+}
+
+// Use the HTTP client for downloads and stream fetching, see SetHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		if client == nil {
+			client = defaultHTTPClient
+		}
+		c.httpClient = client
+	}
+}
+
+// Download FFmpeg to and search it in the dir first, which is created on
+// the first download. See SetInstallDir to check it's writable upfront.
+func WithInstallDir(path string) Option {
+	return func(c *Client) {
+		if path != "" {
+			if dir, err := filepath.Abs(path); err == nil {
+				path = dir
+			}
+		}
+		c.installDir = path
+	}
+}
+
+// Try the mirror prefixes in order when downloading, see
+// SetDownloadMirrors.
+func WithMirrors(mirrors []string) Option {
+	return func(c *Client) {
+		if mirrors == nil {
+			c.downloadMirrors = defaultDownloadMirrors
+			return
+		}
+		c.downloadMirrors = append([]string(nil), mirrors...)
+	}
+}
+
+// Use the logger for messages like download notices, see SetLogger.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// Download the release assets from the base URL, see SetReleaseBaseURL.
+func WithReleaseBaseURL(url string) Option {
+	return func(c *Client) {
+		if url == "" {
+			url = defaultReleaseBaseURL
+		} else if !strings.HasSuffix(url, "/") {
+			url += "/"
+		}
+		c.releaseBaseURL = url
+	}
+}
+
+// Set the max attempts of downloading from each mirror, see
+// SetDownloadAttempts.
+func WithDownloadAttempts(n int) Option {
+	return func(c *Client) {
+		c.downloadAttempts = max(n, 1)
+	}
+}
+
+// Set the timeout of each download attempt, see SetDownloadTimeout.
+func WithDownloadTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.downloadTimeout = d
+	}
+}
+
+// Set the timeout of each m3u8 and ts fetch, see SetStreamTimeout.
+func WithStreamTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.streamTimeout = d
+	}
+}
+
+// Report the download progress to fn, see SetDownloadProgress.
+func WithDownloadProgress(fn func(downloaded, total int64)) Option {
+	return func(c *Client) {
+		c.downloadProgress = fn
+	}
+}
+
+// Extract the binaries in fsys when they can't be downloaded, see
+// SetEmbeddedFS.
+func WithEmbeddedFS(fsys fs.FS) Option {
+	return func(c *Client) {
+		c.embeddedFS = fsys
+	}
+}
+
+// Select variants of HLS master playlists by fn, see SetVariantSelector.
+func WithVariantSelector(fn func(variants []Variant) int) Option {
+	return func(c *Client) {
+		if fn == nil {
+			fn = lowestBandwidth
+		}
+		c.variantSelector = fn
+	}
+}
+
+// Run ffmpeg with the log level, see SetFfmpegLogLevel.
+func WithFfmpegLogLevel(level string) Option {
+	return func(c *Client) {
+		if level == "" {
+			level = defaultFfmpegLogLevel
+		}
+		c.ffmpegLogLevel = level
+	}
+}
+
+// Run ffmpeg extracting frames with n threads, see SetFfmpegThreads.
+func WithFfmpegThreads(n int) Option {
+	return func(c *Client) {
+		c.ffmpegThreads = max(n, 0)
+	}
+}
+
+// Search FFmpeg and FFprobe in the order, see SetSearchOrder.
+func WithSearchOrder(order SearchOrder) Option {
+	return func(c *Client) {
+		c.searchOrder = order
+	}
+}
+
+// Burn FrameOptions.Text with the font file, see SetFontFile.
+func WithFontFile(path string) Option {
+	return func(c *Client) {
+		c.fontFile = path
+	}
+}
+
+// Name FFmpeg and the release assets by the base name and separator, see
+// SetBinaryNaming.
+func WithBinaryNaming(base, sep string) Option {
+	return func(c *Client) {
+		if sep == "" {
+			sep = defaultVariantSep
+		}
+		c.ffmpegBase, c.variantSep = base, sep
+	}
+}
+
+// Preprocess images before scanning barcodes, see SetScanPreprocess.
+func WithScanPreprocess(maxDimension int) Option {
+	return func(c *Client) {
+		c.scanMaxDimension = max(maxDimension, 0)
+	}
+}
+
+// Cache the frames of M3U8GetImage by url, see SetFrameCache.
+func WithFrameCache(ttl time.Duration, maxEntries int) Option {
+	return func(c *Client) {
+		if ttl <= 0 || maxEntries <= 0 {
+			c.frameCache = nil
+			return
+		}
+		c.frameCache = newFrameCache(ttl, maxEntries)
+	}
+}
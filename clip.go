@@ -0,0 +1,87 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"time"
+)
+
+// Cut a clip out of a video as a fragmented mp4 without re-encoding.
+//
+// Stream copy is fast but starts the clip at the keyframe before start,
+// use TrimReencode for an exact cut.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	start: start of the clip
+//	duration: length of the clip
+//
+// Returns:
+//
+//	[]byte: the mp4 clip
+//	error: error
+func Trim(input string, start, duration time.Duration) ([]byte, error) {
+	return defaultClient.Trim(input, start, duration)
+}
+
+// Like Trim, using the client's settings.
+func (c *Client) Trim(
+	input string, start, duration time.Duration) ([]byte, error) {
+	return c.trim(input, start, duration, false)
+}
+
+// Cut a clip out of a video as a fragmented H.264/AAC mp4, re-encoding for
+// an exact cut at start.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	start: start of the clip
+//	duration: length of the clip
+//
+// Returns:
+//
+//	[]byte: the mp4 clip
+//	error: error
+func TrimReencode(
+	input string, start, duration time.Duration) ([]byte, error) {
+	return defaultClient.TrimReencode(input, start, duration)
+}
+
+// Like TrimReencode, using the client's settings.
+func (c *Client) TrimReencode(
+	input string, start, duration time.Duration) ([]byte, error) {
+	return c.trim(input, start, duration, true)
+}
+
+func (c *Client) trim(input string,
+	start, duration time.Duration, reencode bool) ([]byte, error) {
+	if err := c.checkRange(input, start, duration); err != nil {
+		return nil, err
+	}
+	args := []string{
+		"-v", c.getFfmpegLogLevel(), // see SetFfmpegLogLevel
+		"-ss", formatSeconds(start), // seek before opening for speed
+		"-i", input,
+		"-t", formatSeconds(duration),
+	}
+	if reencode {
+		args = append(args, "-c:v", "libx264", "-c:a", "aac")
+	} else {
+		args = append(args,
+			"-c", "copy", // no re-encoding
+			"-avoid_negative_ts", "make_zero", // start at 0 after the seek
+		)
+	}
+	args = append(args,
+		// mp4 needs seeking to write the index unless fragmented
+		"-movflags", "frag_keyframe+empty_moov",
+		"-f", "mp4",
+		"-", // print to stdout
+	)
+	out, _, err := c.RunFfmpeg(context.Background(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,58 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"strconv"
+)
+
+// Get a jpeg image from a DASH stream by its MPD manifest.
+//
+// The manifest is read by ffmpeg's dash demuxer, which fetches the
+// segments itself, picking the highest resolution representation. Reading
+// times out after the stream timeout, see SetStreamTimeout.
+//
+// Args:
+//
+//	url: url of the .mpd manifest
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func MPDGetImage(url string) (image.Image, error) {
+	return defaultClient.MPDGetImage(url)
+}
+
+// Like MPDGetImage, using the client's settings.
+func (c *Client) MPDGetImage(url string) (image.Image, error) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.inputFrameArgs(c.mpdInputArgs(url), 1, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := runFfmpegPipe(context.Background(), ffmpeg, nil, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errNoFrame
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+// Build the ffmpeg input args to read a DASH manifest.
+func (c *Client) mpdInputArgs(url string) []string {
+	input := []string{"-user_agent", userAgent}
+	if d := c.getStreamTimeout(); d > 0 {
+		// read/write timeout of the manifest and segments in microseconds
+		input = append(input, "-rw_timeout", strconv.FormatInt(
+			d.Microseconds(), 10))
+	}
+	return append(input, "-f", "dash", "-i", url)
+}
@@ -0,0 +1,23 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the install dir has no room for the download, wrapped in
+// ErrDownloadFailed
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// Check if dir has room for size bytes, skipped if either is unknown.
+func checkDiskSpace(dir string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	avail, ok := availableSpace(dir)
+	if !ok || avail >= uint64(size) {
+		return nil
+	}
+	return fmt.Errorf("%w in %s: need %d bytes, %d available",
+		ErrInsufficientSpace, dir, size, avail)
+}
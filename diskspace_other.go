@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package ffmpeghelper
+
+// Statfs isn't available, the check is skipped.
+func availableSpace(dir string) (uint64, bool) {
+	return 0, false
+}
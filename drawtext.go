@@ -0,0 +1,104 @@
+package ffmpeghelper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Corner of the text burned into frames.
+type TextPosition string
+
+const (
+	TextTopLeft     TextPosition = "top-left"
+	TextTopRight    TextPosition = "top-right"
+	TextBottomLeft  TextPosition = "bottom-left"
+	TextBottomRight TextPosition = "bottom-right"
+)
+
+// margin between the text and the frame edges in pixels
+const textMargin = 10
+
+// Get the drawtext x and y expressions of the position.
+func (p TextPosition) expr() (string, string) {
+	left, top := fmt.Sprint(textMargin), fmt.Sprint(textMargin)
+	right := fmt.Sprintf("w-tw-%d", textMargin)
+	bottom := fmt.Sprintf("h-th-%d", textMargin)
+	switch p {
+	case TextTopRight:
+		return right, top
+	case TextBottomLeft:
+		return left, bottom
+	case TextBottomRight:
+		return right, bottom
+	}
+	return left, top
+}
+
+// fonts tried in order when no font file is set
+var defaultFontFiles = []string{
+	// linux
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
+	// macos
+	"/System/Library/Fonts/Helvetica.ttc",
+	"/Library/Fonts/Arial.ttf",
+	// windows
+	`C:\Windows\Fonts\arial.ttf`,
+	// android
+	"/system/fonts/Roboto-Regular.ttf",
+}
+
+// Set the font file of the text burned into frames by FrameOptions.Text.
+//
+// By default a common system font like DejaVu Sans or Arial is looked up,
+// or ffmpeg's fontconfig default is used if none exists.
+//
+// Args:
+//
+//	path: path of a .ttf or .ttc font, or "" to restore the lookup
+func SetFontFile(path string) {
+	defaultClient.set(WithFontFile(path))
+}
+
+func (c *Client) getFontFile() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.fontFile != "" {
+		return c.fontFile
+	}
+	for _, path := range defaultFontFiles {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Build the drawtext filter burning the text at the position.
+func drawtextFilter(text string, pos TextPosition, fontFile string) string {
+	x, y := pos.expr()
+	opts := []string{
+		"text=" + escapeFilterValue(text),
+		"expansion=none", // no %{...} expansion
+		"x=" + x, "y=" + y,
+		"fontsize=24", "fontcolor=white",
+		"box=1", "boxcolor=black@0.5", "boxborderw=4", // readable anywhere
+	}
+	if fontFile != "" {
+		opts = append(opts, "fontfile="+escapeFilterValue(fontFile))
+	}
+	return "drawtext=" + strings.Join(opts, ":")
+}
+
+// Escape a filter option value for both the option and the filtergraph
+// level.
+func escapeFilterValue(s string) string {
+	// option level
+	s = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(s)
+	// filtergraph level
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`,
+		`,`, `\,`, `;`, `\;`).Replace(s)
+}
@@ -0,0 +1,120 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errNoEmbedded  = errors.New("no embedded binary")
+	errEmbedBroken = errors.New("embedded binary sha256 mismatch")
+)
+
+// Register a file system, e.g. an embed.FS, holding binaries to be
+// extracted when they can't be downloaded.
+//
+// The binaries are named like the release assets for the current platform,
+// e.g. "ffmpeg_linux_x86_64", each with a sha256sum-style checksum file
+// like "ffmpeg_linux_x86_64.sha256", at the root of fsys. Use fs.Sub for
+// binaries embedded in a sub directory.
+//
+// Args:
+//
+//	fsys: the file system, or nil to unregister
+func SetEmbeddedFS(fsys fs.FS) {
+	defaultClient.set(WithEmbeddedFS(fsys))
+}
+
+func (c *Client) getEmbeddedFS() fs.FS {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.embeddedFS
+}
+
+// Download a binary, or extract the embedded one if the download fails.
+func (c *Client) fetchOrExtract(
+	ctx context.Context, base string) (string, error) {
+	path, err := c.fetchBinary(ctx, base)
+	if err == nil || c.getEmbeddedFS() == nil {
+		return path, err
+	}
+	return c.extractEmbedded(base)
+}
+
+// Extract the embedded binary to the install directory.
+func (c *Client) extractEmbedded(base string) (string, error) {
+	fsys := c.getEmbeddedFS()
+	if fsys == nil {
+		return "", errNoEmbedded
+	}
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return "", err
+	}
+	name := c.getBinaryName(base, variant)
+	data, err := fs.ReadFile(fsys, name+".sha256")
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errNoEmbedded, err)
+	}
+	sum, err := parseSha256(data)
+	if err != nil {
+		return "", err
+	}
+	dir, err := c.makeDownloadDir()
+	if err != nil {
+		return "", err
+	}
+	fetchFfmpegLock.Lock()
+	defer fetchFfmpegLock.Unlock()
+	path := filepath.Join(dir, name)
+	// skip if already extracted
+	if eq, _ := verifySha256(path, sum); eq {
+		return path, chmodExec(path)
+	}
+	// extract to a temp file and move into place once verified
+	tmp := path + ".tmp"
+	if err := extractTo(fsys, name, tmp, sum); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := replaceFile(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+func extractTo(fsys fs.FS, name, path string, sum []byte) error {
+	if err := copyFromFS(fsys, name, path); err != nil {
+		return err
+	}
+	if eq, err := verifySha256(path, sum); err != nil {
+		return err
+	} else if !eq {
+		return errEmbedBroken
+	}
+	// chmod +x
+	return chmodExec(path)
+}
+
+func copyFromFS(fsys fs.FS, name, path string) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNoEmbedded, err)
+	}
+	defer src.Close()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, src); err != nil {
+		return err
+	}
+	return file.Close()
+}
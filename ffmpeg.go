@@ -2,84 +2,175 @@ package ffmpeghelper
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 func getUserBinDir() string {
-	var dir string
-	if home, err := os.UserHomeDir(); err == nil {
-		var d string
-		switch runtime.GOOS {
-		case "windows":
-			// windows
-			d = filepath.Join(home, "AppData", "Local", "Programs")
-		default:
-			// unix-like and others
-			d = filepath.Join(home, ".local", "bin")
-		}
-		dir, _ = filepath.Abs(d)
-	} else {
-		// fallback to the executable's dir on error
-		exe, _ := os.Executable()
-		dir, _ = filepath.Abs(exe)
-	}
+	return userBinDir(runtime.GOOS, os.UserHomeDir)
+}
 
+// Get the user's bin dir on goos, falling back to the executable's dir if
+// homeDir fails, e.g. for windows services running as LocalSystem.
+func userBinDir(goos string, homeDir func() (string, error)) string {
+	home, err := homeDir()
+	if err != nil {
+		return getExecDir()
+	}
+	var d string
+	switch goos {
+	case "windows":
+		// windows
+		d = filepath.Join(home, "AppData", "Local", "Programs")
+	default:
+		// unix-like and others, honor the xdg layout
+		d = getXdgBinDir(home)
+	}
+	dir, _ := filepath.Abs(d)
 	return dir
 }
 
+// guards the package-level settings not tied to a Client
+var settingsLock sync.RWMutex
+
+// Set the directory FFmpeg is downloaded to and searched in first.
+//
+// Args:
+//
+//	path: the install directory, or "" to restore the default
+//
+// Returns:
+//
+//	error: error if the directory cannot be created or is not writable
+func SetInstallDir(path string) error {
+	if path != "" {
+		dir, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("cannot create install dir %s: %w", dir, err)
+		}
+		// check if the dir is writable
+		file, err := os.CreateTemp(dir, ".ffmpeghelper-*")
+		if err != nil {
+			return fmt.Errorf("install dir %s is not writable: %w", dir, err)
+		}
+		file.Close()
+		os.Remove(file.Name())
+		path = dir
+	}
+	defaultClient.set(WithInstallDir(path))
+	return nil
+}
+
+// Get the configured install dir, or "" if not set.
+func (c *Client) getInstallDir() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.installDir
+}
+
+// Get the dir FFmpeg is downloaded to.
+func (c *Client) getDownloadDir() string {
+	if dir := c.getInstallDir(); dir != "" {
+		return dir
+	}
+	return getUserBinDir()
+}
+
+// Run the executable with -version and capture its stdout.
+func runVersion(path string) ([]byte, error) {
+	cmd := exec.Command(path, "-version")
+	out := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, nil
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Get $XDG_BIN_HOME, or the bin dir next to $XDG_DATA_HOME, or
+// ~/.local/bin.
+func getXdgBinDir(home string) string {
+	// relative paths are invalid per the xdg spec
+	if d := os.Getenv("XDG_BIN_HOME"); filepath.IsAbs(d) {
+		return d
+	}
+	if d := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(d) {
+		// ~/.local/share -> ~/.local/bin
+		return filepath.Join(filepath.Dir(filepath.Clean(d)), "bin")
+	}
+	return filepath.Join(home, ".local", "bin")
+}
+
 func isValidFfmpegExe(path string) bool {
-	// check if file exists and not a dir
+	// check if file exists and not a dir, following symlinks so a broken
+	// one is rejected
 	if info, err := os.Stat(path); err != nil || info.IsDir() {
 		return false
 	}
 	// check if file executes
-	cmd := exec.Command(path, "-version")
-	cmd.Stdout, cmd.Stderr = nil, nil
-	if err := cmd.Run(); err == nil {
+	if _, err := runVersion(path); err == nil {
 		return true
 	}
 	return false
 }
 
-func getFfmpegVariant() string {
-	o := runtime.GOOS
-	var a string
-	switch runtime.GOARCH {
-	case "amd64":
-		a = "x86_64"
-	case "386":
-		a = "i686"
-	case "arm":
-		switch o {
-		case "android":
-			a = "armv7a"
-		default:
-			a = "armhf"
-		}
-	case "arm64":
-		a = "aarch64"
-	case "loong64":
-		a = "loongarch64"
-	default:
-		a = runtime.GOARCH
+const defaultVariantSep = "_"
+
+// Set the naming of the FFmpeg binary and the release assets, e.g. for a
+// fork publishing "myffmpeg-linux_x86_64".
+//
+// Both GetFfmpegPath and FetchFfmpeg use it. FFprobe keeps its base name
+// but is joined with the separator too, e.g. "ffprobe-linux_x86_64".
+//
+// Args:
+//
+//	base: base name of FFmpeg, or "" to restore "ffmpeg"
+//	sep: separator joining the base name and the variant, or "" to
+//	restore "_"
+func SetBinaryNaming(base, sep string) {
+	defaultClient.set(WithBinaryNaming(base, sep))
+}
+
+// Get the configured base name of a binary like ffmpeg or ffprobe.
+func (c *Client) getBaseName(base string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if base == "ffmpeg" && c.ffmpegBase != "" {
+		return c.ffmpegBase
 	}
-	return o + "_" + a
+	return base
+}
+
+func (c *Client) getVariantSep() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.variantSep
 }
 
-func getFfmpegName(variant string) string {
-	name := "ffmpeg"
+// Get the file name of a binary like ffmpeg or ffprobe.
+func (c *Client) getBinaryName(base, variant string) string {
+	name := c.getBaseName(base)
 	if variant != "" {
-		name += "_" + variant
+		name += c.getVariantSep() + variant
 	}
 	switch runtime.GOOS {
 	case "windows":
@@ -88,6 +179,16 @@ func getFfmpegName(variant string) string {
 	return name
 }
 
+// Get the file name a binary is downloaded to, suffixed by the variant so
+// hosts of different platforms sharing the dir don't clobber each other.
+func (c *Client) getInstalledName(base string) string {
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return c.getBinaryName(base, "")
+	}
+	return c.getBinaryName(base, variant)
+}
+
 func getExecDir() string {
 	if ex, err := os.Executable(); err == nil {
 		return filepath.Dir(ex)
@@ -97,15 +198,80 @@ func getExecDir() string {
 
 // Get path of FFmpeg.
 //
+// The FFMPEG_PATH environment variable is checked first, then the install
+// dir, the executable's dir, the user's bin dir and PATH, or PATH first
+// with SearchPathFirst, see SetSearchOrder.
+//
 // Returns:
 //
 //	string: path of the executable
 func GetFfmpegPath() string {
-	names := []string{getFfmpegName("")}
+	return defaultClient.GetFfmpegPath()
+}
+
+// Like GetFfmpegPath, using the client's settings.
+func (c *Client) GetFfmpegPath() string {
+	return c.findBinary("ffmpeg", "FFMPEG_PATH")
+}
+
+// Get the real path of FFmpeg with symlinks resolved, or download it if not
+// yet.
+//
+// The cached path of Ffmpeg stays the symlink, e.g. Homebrew's, so it keeps
+// working when the symlink is relinked to an upgraded binary.
+//
+// Returns:
+//
+//	string: real path on success
+//	error: error
+func ResolvedFfmpegPath() (string, error) {
+	return defaultClient.ResolvedFfmpegPath()
+}
+
+// Like ResolvedFfmpegPath, using the client's settings.
+func (c *Client) ResolvedFfmpegPath() (string, error) {
+	path, err := c.Ffmpeg()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Find a binary by its base name, preferring the path in the env variable.
+func (c *Client) findBinary(base, env string) string {
+	// prefer the path pinned by the environment
+	if path := os.Getenv(env); path != "" {
+		if isValidFfmpegExe(path) {
+			return path
+		}
+		c.logWarn(env+" is not a valid "+base+" executable, ignored",
+			"path", path)
+	}
+	// the current variant's download first, then the plain name of older
+	// downloads and system installs
+	names := []string{c.getInstalledName(base)}
+	if plain := c.getBinaryName(base, ""); plain != names[0] {
+		names = append(names, plain)
+	}
 	if runtime.GOOS == "android" {
-		names = append(names, "libffmpeg.so")
+		names = append(names, "lib"+c.getBaseName(base)+".so")
+	}
+	pathFirst := c.getSearchOrder() == SearchPathFirst
+	if pathFirst {
+		// find any name in os path before the downloaded copies
+		for _, name := range names {
+			if path := lookPathExe(name); path != "" {
+				return path
+			}
+		}
 	}
 	for _, name := range names {
+		if dir := c.getInstallDir(); dir != "" {
+			// find in the configured install dir
+			if path := filepath.Join(dir, name); isValidFfmpegExe(path) {
+				return path
+			}
+		}
 		if path := filepath.Join(getExecDir(), name); isValidFfmpegExe(path) {
 			// find in the same dir
 			return path
@@ -113,8 +279,7 @@ func GetFfmpegPath() string {
 			getUserBinDir(), name); isValidFfmpegExe(path) {
 			// find in user bin dir
 			return path
-		} else if path, err := exec.LookPath(
-			name); err == nil && isValidFfmpegExe(path) {
+		} else if path := lookPathExe(name); !pathFirst && path != "" {
 			// find in os path
 			return path
 		}
@@ -122,51 +287,352 @@ func GetFfmpegPath() string {
 	return ""
 }
 
+// Find a valid executable in PATH, "" if none.
+func lookPathExe(name string) string {
+	if path, err := exec.LookPath(name); err == nil && isValidFfmpegExe(path) {
+		return path
+	}
+	return ""
+}
+
+// Order of the dirs searched for FFmpeg and FFprobe.
+type SearchOrder int
+
+const (
+	// the install dir, the executable's dir and the user's bin dir, then
+	// PATH
+	SearchLocalFirst SearchOrder = iota
+	// PATH first, then the dirs of SearchLocalFirst, so a download is a
+	// last resort
+	SearchPathFirst
+)
+
+// Set the order of the dirs searched for FFmpeg and FFprobe, e.g.
+// SearchPathFirst to prefer an up-to-date system FFmpeg over an old
+// download.
+//
+// The FFMPEG_PATH and FFPROBE_PATH environment variables still come first.
+// The cached paths are cleared to apply it on the next call.
+//
+// Args:
+//
+//	order: SearchLocalFirst by default, or SearchPathFirst
+func SetSearchOrder(order SearchOrder) {
+	defaultClient.set(WithSearchOrder(order))
+	defaultClient.ClearCache()
+}
+
+func (c *Client) getSearchOrder() SearchOrder {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.searchOrder
+}
+
 const userAgent = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 " +
 	"(KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36"
 
+// timeouts are applied per request, see SetDownloadTimeout, and the nil
+// Transport is http.DefaultTransport at the time of each request, which
+// honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+var defaultHTTPClient = &http.Client{}
+
 var (
-	httpClient        = &http.Client{Timeout: time.Minute * 15}
-	errDownloadFailed = errors.New("binary fetching failed")
-	errFileCorrupted  = errors.New("binary sha256 mismatch")
+	// downloading the binary failed, e.g. on network errors or a bad status
+	ErrDownloadFailed = errors.New("binary fetching failed")
+	// the downloaded binary doesn't match its checksum
+	ErrFileCorrupted = errors.New("binary checksum mismatch")
+)
+
+// Set the HTTP client used for downloads and stream fetching.
+//
+// The default client uses http.DefaultTransport, which honors HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY. Set the Proxy of a custom client's transport to
+// http.ProxyFromEnvironment to keep that.
+//
+// Args:
+//
+//	client: the client, or nil to restore the default
+func SetHTTPClient(client *http.Client) {
+	defaultClient.set(WithHTTPClient(client))
+}
+
+func (c *Client) getHTTPClient() *http.Client {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.httpClient
+}
+
+const (
+	defaultDownloadTimeout = 15 * time.Minute
+	defaultStreamTimeout   = 10 * time.Second
 )
 
-func downloadFile(url, path string) error {
-	req, err := http.NewRequest("GET", url, nil)
+// Set the timeout of each binary download attempt.
+//
+// Args:
+//
+//	d: the timeout, or 0 for no timeout
+func SetDownloadTimeout(d time.Duration) {
+	defaultClient.set(WithDownloadTimeout(d))
+}
+
+// Set the timeout of each m3u8 and ts fetch when reading streams.
+//
+// Args:
+//
+//	d: the timeout, or 0 for no timeout
+func SetStreamTimeout(d time.Duration) {
+	defaultClient.set(WithStreamTimeout(d))
+}
+
+func (c *Client) getDownloadTimeout() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.downloadTimeout
+}
+
+func (c *Client) getStreamTimeout() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.streamTimeout
+}
+
+// Derive a context with the timeout, or without one if d <= 0.
+func withTimeout(
+	ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// io.Reader that stops reading once the context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// io.Reader that reports the download progress periodically
+type progressReader struct {
+	r          io.Reader
+	fn         func(downloaded, total int64)
+	downloaded int64
+	total      int64
+	reported   int64
+	reportedAt time.Time
+}
+
+const (
+	progressBytes    = 64 * 1024
+	progressInterval = 200 * time.Millisecond
+)
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.downloaded += int64(n)
+	if r.downloaded-r.reported >= progressBytes ||
+		time.Since(r.reportedAt) >= progressInterval {
+		r.report()
+	}
+	return n, err
+}
+
+func (r *progressReader) report() {
+	r.reported, r.reportedAt = r.downloaded, time.Now()
+	r.fn(r.downloaded, r.total)
+}
+
+// report downloaded == total once the copy has finished
+func (r *progressReader) done() {
+	r.total = r.downloaded
+	r.report()
+}
+
+// Set a callback to be notified of the download progress of FetchFfmpeg.
+//
+// Args:
+//
+//	fn: called with the downloaded bytes and the total bytes (-1 if
+//	unknown), or nil to disable
+func SetDownloadProgress(fn func(downloaded, total int64)) {
+	defaultClient.set(WithDownloadProgress(fn))
+}
+
+func (c *Client) getDownloadProgress() func(downloaded, total int64) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.downloadProgress
+}
+
+// Download a file, resuming a partial one, and return its ETag.
+func (c *Client) downloadFile(
+	ctx context.Context, url, path string) (string, error) {
+	// resume from an existing partial file
+	var offset int64
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		offset = info.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("User-Agent", userAgent)
-	res, err := httpClient.Do(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := c.getHTTPClient().Do(req)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer res.Body.Close()
+	etag := res.Header.Get("ETag")
+	var file *os.File
+	switch {
+	case res.StatusCode == 206 && strings.HasPrefix(
+		res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		// append to the partial file
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	case res.StatusCode == 200:
+		// no range support, start fresh
+		offset = 0
+		file, err = os.Create(path)
+	case res.StatusCode == 206 || res.StatusCode == 416:
+		// partial file doesn't match the remote, start over
+		res.Body.Close()
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+		return c.downloadFile(ctx, url, path)
+	default:
+		return "", &statusError{res.StatusCode}
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	// make sure the rest fits before starting the copy
+	if err := checkDiskSpace(
+		filepath.Dir(path), res.ContentLength); err != nil {
+		return "", err
+	}
+	var body io.Reader = &ctxReader{ctx, res.Body}
+	var progress *progressReader
+	if fn := c.getDownloadProgress(); fn != nil {
+		total := res.ContentLength
+		if total >= 0 {
+			total += offset
+		}
+		progress = &progressReader{
+			r: body, fn: fn, total: total,
+			downloaded: offset, reported: offset, reportedAt: time.Now(),
+		}
+		body = progress
+	}
+	// hash while writing instead of reading the file back, only the
+	// resumed prefix is read
+	sha256Hasher, md5Hasher := sha256.New(), md5.New()
+	hashers := io.MultiWriter(sha256Hasher, md5Hasher)
+	if offset > 0 {
+		if err := hashPrefix(hashers, path, offset); err != nil {
+			return "", err
+		}
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hashers), body); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	if progress != nil {
+		progress.done()
+	}
+	// verify hash over the complete file, preferring the companion sha256
+	if sum, err := c.fetchSha256(ctx, url+".sha256"); err == nil {
+		if bytes.Equal(sum, sha256Hasher.Sum(nil)) {
+			return etag, nil
+		}
+	} else if v, ok := res.Header["X-Ms-Blob-Content-Md5"]; ok {
+		sum, err := base64.StdEncoding.DecodeString(v[0])
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(sum, md5Hasher.Sum(nil)) {
+			return etag, nil
+		}
+	}
+	// drop the corrupted file so the next attempt is clean
+	os.Remove(path)
+	return "", ErrFileCorrupted
+}
+
+var errChecksumInvalid = errors.New("invalid checksum file")
+
+// Fetch a sha256sum-style checksum file and parse the digest.
+func (c *Client) fetchSha256(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	res, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return errDownloadFailed
+		return nil, ErrDownloadFailed
 	}
-	// save to path without variant in name
-	file, err := os.Create(path)
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		return nil, err
+	}
+	return parseSha256(body)
+}
+
+// Parse a sha256sum-style checksum file.
+func parseSha256(body []byte) ([]byte, error) {
+	// "<hex digest>  <file name>"
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return nil, errChecksumInvalid
+	}
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errChecksumInvalid
+	}
+	return sum, nil
+}
+
+// Hash the first n bytes of a partial file being resumed.
+func hashPrefix(w io.Writer, path string, n int64) error {
+	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	if _, err := io.Copy(file, res.Body); err != nil {
-		return err
+	_, err = io.CopyN(w, file, n)
+	return err
+}
+
+func verifySha256(path string, sum []byte) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
 	}
-	// verify hash
-	if v, ok := res.Header["X-Ms-Blob-Content-Md5"]; ok {
-		if sum, err := base64.StdEncoding.DecodeString(v[0]); err == nil {
-			if eq, err := verifyMd5(path, sum); eq {
-				return nil
-			} else {
-				return err
-			}
-		} else {
-			return err
-		}
+	defer file.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return false, err
 	}
-	return errFileCorrupted
+	fsum := hasher.Sum(nil)
+	return bytes.Equal(sum, fsum), nil
 }
 
 func verifyMd5(path string, sum []byte) (bool, error) {
@@ -184,94 +650,875 @@ func verifyMd5(path string, sum []byte) (bool, error) {
 }
 
 func chmodExec(path string) error {
+	// there's no exec bit on windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
 	info, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
 	// u+x, g+x, o+x
-	return os.Chmod(path, info.Mode()|0111)
+	if err := os.Chmod(path, info.Mode()|0111); err != nil &&
+		info.Mode()&0111 == 0 {
+		// only fatal if it isn't executable already
+		return err
+	}
+	return nil
 }
 
+const defaultReleaseBaseURL = "https://github.com/StellarForager/FFmpeg/" +
+	"releases/latest/download/"
+
+// Set the base URL the release assets are downloaded from.
+//
+// The asset name of the current platform, e.g. "ffmpeg_linux_x86_64", is
+// appended to it. The download mirrors are still prepended, so use
+// SetDownloadMirrors([]string{""}) for a direct download from the base.
+//
+// Args:
+//
+//	url: the base URL, or "" to restore the GitHub release
+func SetReleaseBaseURL(url string) {
+	defaultClient.set(WithReleaseBaseURL(url))
+}
+
+func (c *Client) getReleaseBaseURL() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.releaseBaseURL
+}
+
+var defaultDownloadMirrors = []string{
+	"https://ghfast.top/",
+	"https://gh-proxy.com/",
+	"",
+}
+
+// Set the mirror prefixes tried in order when downloading.
+//
+// Each prefix is prepended to the GitHub release URL, e.g.
+// "https://ghfast.top/" fetches
+// "https://ghfast.top/https://github.com/...". An empty string stands for
+// the direct download and is only tried if included.
+//
+// Args:
+//
+//	mirrors: the prefixes, or nil to restore the default list
+func SetDownloadMirrors(mirrors []string) {
+	defaultClient.set(WithMirrors(mirrors))
+}
+
+func (c *Client) getDownloadMirrors() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.downloadMirrors
+}
+
+// Error of an unexpected HTTP status while downloading.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%v: http status %d", ErrDownloadFailed, e.code)
+}
+
+func (e *statusError) Unwrap() error {
+	return ErrDownloadFailed
+}
+
+// Only network errors and 5xx are worth retrying, a 404 means the
+// variant doesn't exist.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+const defaultDownloadAttempts = 3
+
+// Set the max attempts of downloading from each mirror.
+//
+// Attempts are retried on network errors and 5xx with an exponential
+// backoff of 1s, 2s, 4s, ...
+//
+// Args:
+//
+//	n: the max attempts, at least 1
+func SetDownloadAttempts(n int) {
+	defaultClient.set(WithDownloadAttempts(n))
+}
+
+func (c *Client) getDownloadAttempts() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.downloadAttempts
+}
+
+// Download a file, retrying with an exponential backoff, and return its
+// ETag.
+func (c *Client) downloadWithRetry(
+	ctx context.Context, url, path string) (string, error) {
+	backoff := time.Second
+	var err error
+	for i := range c.getDownloadAttempts() {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				// cancelled during the backoff, keep the cause of the retry
+				return "", errors.Join(ctx.Err(), err)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		attemptCtx, cancel := withTimeout(ctx, c.getDownloadTimeout())
+		var etag string
+		etag, err = c.downloadFile(attemptCtx, url, path)
+		cancel()
+		if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+			return "", errors.Join(ctx.Err(), err)
+		}
+		if err == nil || ctx.Err() != nil || !isRetryable(err) {
+			return etag, err
+		}
+	}
+	return "", err
+}
+
+// guards downloads of both ffmpeg and ffprobe
 var fetchFfmpegLock sync.Mutex
 
-// Download FFmpeg to the user's bin directory.
+// Download FFmpeg to the install directory.
+//
+// The binary is named after the platform variant, e.g. ffmpeg_linux_x86_64,
+// so hosts of different platforms can share the directory.
 //
 // Returns:
 //
 //	string: path on success
 //	error: error
 func FetchFfmpeg() (string, error) {
-	// get a matching variant from the latest release
-	url :=
-		"https://github.com/StellarForager/FFmpeg/releases/latest/download/" +
-			getFfmpegName(getFfmpegVariant())
-	// create dir
-	dir := getUserBinDir()
-	if err := os.MkdirAll(dir, 0755); err != nil && !os.IsExist(err) {
+	return defaultClient.FetchFfmpeg()
+}
+
+// Like FetchFfmpeg, using the client's settings.
+func (c *Client) FetchFfmpeg() (string, error) {
+	return c.FetchFfmpegContext(context.Background())
+}
+
+// Download FFmpeg to the install directory, aborting when ctx is done.
+//
+// Args:
+//
+//	ctx: context to cancel the download
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func FetchFfmpegContext(ctx context.Context) (string, error) {
+	return defaultClient.FetchFfmpegContext(ctx)
+}
+
+// Like FetchFfmpegContext, using the client's settings.
+func (c *Client) FetchFfmpegContext(ctx context.Context) (string, error) {
+	return c.fetchBinary(ctx, "ffmpeg")
+}
+
+// Download a binary like ffmpeg or ffprobe to the install directory.
+func (c *Client) fetchBinary(ctx context.Context, base string) (string, error) {
+	dir, err := c.makeDownloadDir()
+	if err != nil {
 		return "", err
 	}
 	// download the binary
 	fetchFfmpegLock.Lock()
 	defer fetchFfmpegLock.Unlock()
-	path := filepath.Join(dir, getFfmpegName(""))
+	path := filepath.Join(dir, c.getInstalledName(base))
+	if err := c.downloadBinary(ctx, base, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Create the download dir if not exists.
+func (c *Client) makeDownloadDir() (string, error) {
+	dir := c.getDownloadDir()
+	if err := os.MkdirAll(dir, 0755); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("cannot create install dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// Check if FFmpeg of the current platform can be downloaded, without
+// downloading it.
+//
+// The release URL is requested with HEAD through the same mirrors as
+// FetchFfmpeg, each timing out like stream fetches, see SetStreamTimeout.
+//
+// Returns:
+//
+//	bool: whether the asset exists
+//	int64: size of the asset in bytes, -1 if unknown
+//	error: error if no mirror is reachable, or ErrPlatformUnsupported
+func FfmpegAvailable() (bool, int64, error) {
+	return defaultClient.FfmpegAvailable()
+}
+
+// Like FfmpegAvailable, using the client's settings.
+func (c *Client) FfmpegAvailable() (bool, int64, error) {
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return false, 0, err
+	}
+	url := c.getReleaseBaseURL() + c.getBinaryName("ffmpeg", variant)
+	notFound := false
+	headErr := ErrDownloadFailed
+	// try proxies in order
+	for _, proxy := range c.getDownloadMirrors() {
+		res, err := c.head(proxy+url, "")
+		if err == nil {
+			return true, res.ContentLength, nil
+		}
+		var se *statusError
+		if errors.As(err, &se) && se.code == 404 {
+			notFound = true
+		}
+		headErr = err
+	}
+	// a mirror answered, the asset doesn't exist
+	if notFound {
+		return false, 0, nil
+	}
+	return false, 0, headErr
+}
+
+// Send a HEAD request for the headers of a remote file, conditional on the
+// ETag if not empty.
+func (c *Client) head(url, etag string) (*http.Response, error) {
+	ctx, cancel := withTimeout(context.Background(), c.getStreamTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 && res.StatusCode != 304 {
+		return nil, &statusError{res.StatusCode}
+	}
+	return res, nil
+}
+
+// Remote metadata of the FFmpeg release asset.
+type AssetInfo struct {
+	// size in bytes, -1 if unknown
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// whether the asset has the ETag of the installed binary
+	NotModified bool
+}
+
+// Get the metadata of the FFmpeg release asset to tell if the installed
+// binary is stale, without downloading it.
+//
+// The ETag of each download is stored next to the binary, and the asset is
+// requested with HEAD and If-None-Match through the same mirrors as
+// FetchFfmpeg.
+//
+// Returns:
+//
+//	*AssetInfo: metadata of the asset
+//	error: error if no mirror is reachable, or ErrPlatformUnsupported
+func FfmpegAssetInfo() (*AssetInfo, error) {
+	return defaultClient.FfmpegAssetInfo()
+}
+
+// Like FfmpegAssetInfo, using the client's settings.
+func (c *Client) FfmpegAssetInfo() (*AssetInfo, error) {
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return nil, err
+	}
+	url := c.getReleaseBaseURL() + c.getBinaryName("ffmpeg", variant)
+	path := filepath.Join(c.getDownloadDir(), c.getInstalledName("ffmpeg"))
+	stored := readETag(path)
+	headErr := ErrDownloadFailed
+	// try proxies in order
+	for _, proxy := range c.getDownloadMirrors() {
+		res, err := c.head(proxy+url, stored)
+		if err != nil {
+			headErr = err
+			continue
+		}
+		info := &AssetInfo{
+			Size: res.ContentLength,
+			ETag: res.Header.Get("ETag"),
+		}
+		lastModified := res.Header.Get("Last-Modified")
+		if t, err := http.ParseTime(lastModified); err == nil {
+			info.LastModified = t
+		}
+		if res.StatusCode == 304 {
+			// no body headers on a 304, the size is unknown
+			info.Size = -1
+			if info.ETag == "" {
+				info.ETag = stored
+			}
+		}
+		info.NotModified = stored != "" && info.ETag == stored
+		return info, nil
+	}
+	return nil, headErr
+}
+
+// Read the ETag stored next to a downloaded binary, empty if none.
+func readETag(path string) string {
+	data, err := os.ReadFile(path + ".etag")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Store the ETag next to a downloaded binary, dropping a stale one if empty.
+func writeETag(path, etag string) {
+	if etag == "" {
+		os.Remove(path + ".etag")
+		return
+	}
+	os.WriteFile(path+".etag", []byte(etag), 0644)
+}
+
+// Check if the FFmpeg downloaded to the install dir matches the checksum
+// published with the release, without downloading it.
+//
+// The companion sha256 file is preferred, then the md5 header of the
+// asset, both fetched through the same mirrors as FetchFfmpeg.
+//
+// Returns:
+//
+//	bool: whether the binary matches
+//	error: ErrFfmpegNotFound if not installed, or error if no checksum
+//	can be fetched
+func VerifyInstalledFfmpeg() (bool, error) {
+	return defaultClient.VerifyInstalledFfmpeg()
+}
+
+// Like VerifyInstalledFfmpeg, using the client's settings.
+func (c *Client) VerifyInstalledFfmpeg() (bool, error) {
+	path := filepath.Join(c.getDownloadDir(), c.getInstalledName("ffmpeg"))
+	if info, err := os.Stat(path); os.IsNotExist(err) {
+		return false, fmt.Errorf("%w: %s", ErrFfmpegNotFound, path)
+	} else if err != nil {
+		return false, err
+	} else if info.IsDir() {
+		return false, fmt.Errorf("%w: %s is a directory",
+			ErrFfmpegNotFound, path)
+	}
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return false, err
+	}
+	url := c.getReleaseBaseURL() + c.getBinaryName("ffmpeg", variant)
+	lastErr := ErrDownloadFailed
+	// try proxies in order, each within its own stream timeout
+	for _, proxy := range c.getDownloadMirrors() {
+		ctx, cancel := withTimeout(context.Background(), c.getStreamTimeout())
+		sum, err := c.fetchSha256(ctx, proxy+url+".sha256")
+		cancel()
+		if err == nil {
+			return verifySha256(path, sum)
+		}
+		res, err := c.head(proxy+url, "")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if v, ok := res.Header["X-Ms-Blob-Content-Md5"]; ok {
+			sum, err := base64.StdEncoding.DecodeString(v[0])
+			if err != nil {
+				return false, err
+			}
+			return verifyMd5(path, sum)
+		}
+		lastErr = fmt.Errorf("%w: no checksum published", ErrDownloadFailed)
+	}
+	return false, lastErr
+}
+
+// Download the matching variant of a binary to path and make it executable.
+func (c *Client) downloadBinary(ctx context.Context, base, path string) error {
+	// get a matching variant from the latest release
+	variant, err := getFfmpegVariant()
+	if err != nil {
+		return err
+	}
+	url := c.getReleaseBaseURL() + c.getBinaryName(base, variant)
+	// download to a temp file so a partial binary is never at path, the
+	// temp file is resumed across attempts
+	tmp := path + ".tmp"
 	isDownloadFailed := true
-	var dlErr error
-	// try proxies first
-	for _, proxy := range []string{
-		"https://ghfast.top/",
-		"https://gh-proxy.com/",
-		"",
-	} {
-		if err := downloadFile(
-			proxy+url, path); err == nil {
+	dlErr := ErrDownloadFailed
+	var etag string
+	// try proxies in order
+	for _, proxy := range c.getDownloadMirrors() {
+		var err error
+		etag, err = c.downloadWithRetry(ctx, proxy+url, tmp)
+		if err == nil {
 			isDownloadFailed = false
 			break
-		} else {
-			dlErr = err
+		}
+		dlErr = err
+		// stop trying other proxies once cancelled
+		if ctx.Err() != nil {
+			break
 		}
 	}
 	if isDownloadFailed {
-		os.Remove(path)
-		return "", dlErr
+		os.Remove(tmp)
+		// keep a checksum mismatch distinguishable from network errors
+		if errors.Is(dlErr, ErrDownloadFailed) ||
+			errors.Is(dlErr, ErrFileCorrupted) {
+			return dlErr
+		}
+		return fmt.Errorf("%w: %w", ErrDownloadFailed, dlErr)
 	}
 	// chmod +x
-	if err := chmodExec(path); err != nil {
+	if err := chmodExec(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// move into place
+	if err := replaceFile(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	writeETag(path, etag)
+	return nil
+}
+
+// Download the latest FFmpeg even if it exists, replacing the old one
+// unless it's already the latest.
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func UpdateFfmpeg() (string, error) {
+	return defaultClient.UpdateFfmpeg()
+}
+
+// Like UpdateFfmpeg, using the client's settings.
+func (c *Client) UpdateFfmpeg() (string, error) {
+	return c.UpdateFfmpegContext(context.Background())
+}
+
+// Download the latest FFmpeg even if it exists, aborting when ctx is done.
+//
+// The download is skipped if the installed binary has the ETag of the
+// release asset, see FfmpegAssetInfo.
+//
+// Args:
+//
+//	ctx: context to cancel the download
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func UpdateFfmpegContext(ctx context.Context) (string, error) {
+	return defaultClient.UpdateFfmpegContext(ctx)
+}
+
+// Like UpdateFfmpegContext, using the client's settings.
+func (c *Client) UpdateFfmpegContext(ctx context.Context) (string, error) {
+	dir, err := c.makeDownloadDir()
+	if err != nil {
 		return "", err
 	}
+	fetchFfmpegLock.Lock()
+	defer fetchFfmpegLock.Unlock()
+	path := filepath.Join(dir, c.getInstalledName("ffmpeg"))
+	if isValidFfmpegExe(path) {
+		if info, err := c.FfmpegAssetInfo(); err == nil && info.NotModified {
+			return path, nil
+		}
+	}
+	// the old binary keeps working until atomically replaced
+	if err := c.downloadBinary(ctx, "ffmpeg", path); err != nil {
+		return "", err
+	}
+	// re-resolve on the next Ffmpeg() call
+	c.ffmpegPath.set("")
 	return path, nil
 }
 
-var (
-	ffmpegPath        string
-	errFfmpegNotFound = errors.New("cannot find executable ffmpeg")
-)
+// Remove the FFmpeg and FFprobe binaries downloaded to the install dir.
+//
+// Copies found elsewhere, e.g. on PATH, are left untouched. It's a no-op if
+// nothing was installed.
+//
+// Returns:
+//
+//	error: error
+func Uninstall() error {
+	return defaultClient.Uninstall()
+}
+
+// Like Uninstall, using the client's settings.
+func (c *Client) Uninstall() error {
+	fetchFfmpegLock.Lock()
+	defer fetchFfmpegLock.Unlock()
+	dir := c.getDownloadDir()
+	for _, base := range []string{"ffmpeg", "ffprobe"} {
+		// also the plain name of older downloads
+		for _, name := range []string{
+			c.getInstalledName(base), c.getBinaryName(base, ""),
+		} {
+			path := filepath.Join(dir, name)
+			for _, p := range []string{path, path + ".tmp", path + ".etag"} {
+				if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+	c.ClearCache()
+	return nil
+}
+
+// Remove the leftovers of interrupted downloads in the install dir, e.g.
+// at startup after the process was killed during FetchFfmpeg.
+//
+// The temp files and the replaced binaries moved aside on windows are
+// removed, and so is a downloaded binary of the current platform that
+// doesn't execute, which is downloaded again when needed.
+// Another process downloading to the same dir at the time is disrupted.
+//
+// Returns:
+//
+//	error: error
+func CleanupDownloads() error {
+	return defaultClient.CleanupDownloads()
+}
+
+// Like CleanupDownloads, using the client's settings.
+func (c *Client) CleanupDownloads() error {
+	fetchFfmpegLock.Lock()
+	defer fetchFfmpegLock.Unlock()
+	dir := c.getDownloadDir()
+	for _, base := range []string{"ffmpeg", "ffprobe"} {
+		installed := filepath.Join(dir, c.getInstalledName(base))
+		plain := filepath.Join(dir, c.getBinaryName(base, ""))
+		for _, p := range []string{installed + ".tmp", plain + ".tmp"} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// left by replaceFile on windows, may fail while it's still running
+		os.Remove(installed + ".old")
+		os.Remove(plain + ".old")
+		// other platforms' binaries may be in a shared dir, only check the
+		// current one's
+		info, err := os.Lstat(installed)
+		if err != nil || !info.Mode().IsRegular() || isValidFfmpegExe(installed) {
+			continue
+		}
+		for _, p := range []string{installed, installed + ".etag"} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	c.ClearCache()
+	return nil
+}
+
+// Atomically replace dst by src.
+func replaceFile(src, dst string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(src, dst)
+	}
+	// a running executable can't be overwritten on windows but can be
+	// renamed, so move the old one aside first
+	old := dst + ".old"
+	os.Remove(old)
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		// restore the old one
+		os.Rename(old, dst)
+		return err
+	}
+	// may fail while the old one is still running
+	os.Remove(old)
+	return nil
+}
+
+// Resolved path of a binary, safe for concurrent use.
+type pathCache struct {
+	lock sync.RWMutex
+	path string
+}
+
+func (c *pathCache) get() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.path
+}
+
+func (c *pathCache) set(path string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.path = path
+}
+
+// Clear the cached paths of FFmpeg and FFprobe.
+//
+// The next Ffmpeg() or Ffprobe() call searches for the binary again, which
+// is useful after the binary is moved or updated.
+func ClearCache() {
+	defaultClient.ClearCache()
+}
+
+// Like ClearCache, for the binaries resolved by the client.
+func (c *Client) ClearCache() {
+	c.ffmpegPath.set("")
+	c.ffprobePath.set("")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ffmpegMajors = nil
+}
+
+// no valid FFmpeg is found, even after downloading
+var ErrFfmpegNotFound = errors.New("cannot find executable ffmpeg")
 
 // Get FFmpeg's path or download it if not yet.
 //
+// The binary registered by SetEmbeddedFS is extracted if the download
+// fails.
+//
 // Returns:
 //
 //	string: path on success
 //	error: error
 func Ffmpeg() (string, error) {
+	return defaultClient.Ffmpeg()
+}
+
+// Like Ffmpeg, using the client's settings.
+func (c *Client) Ffmpeg() (string, error) {
+	path, _, err := c.FfmpegWithStatus()
+	return path, err
+}
+
+// Get FFmpeg's path or download it if not yet, telling if downloaded.
+//
+// Concurrent callers sharing the same download all report it.
+//
+// Returns:
+//
+//	string: path on success
+//	bool: whether FFmpeg was downloaded by this call
+//	error: error
+func FfmpegWithStatus() (string, bool, error) {
+	return defaultClient.FfmpegWithStatus()
+}
+
+// Like FfmpegWithStatus, using the client's settings.
+func (c *Client) FfmpegWithStatus() (string, bool, error) {
+	return c.resolveFfmpeg(context.Background())
+}
+
+// Resolve FFmpeg at startup, downloading it if not yet, so the first
+// request doesn't pay for the download.
+//
+// It's safe to call concurrently and a no-op once resolved. The download
+// is shared with the concurrent callers, so it goes on in the background
+// when ctx is done.
+//
+// Args:
+//
+//	ctx: context to stop waiting for the download
+//
+// Returns:
+//
+//	error: error
+func EnsureFfmpeg(ctx context.Context) error {
+	return defaultClient.EnsureFfmpeg(ctx)
+}
+
+// Like EnsureFfmpeg, using the client's settings.
+func (c *Client) EnsureFfmpeg(ctx context.Context) error {
+	_, _, err := c.resolveFfmpeg(ctx)
+	return err
+}
+
+// Resolve FFmpeg, downloading it if not found until ctx is done.
+func (c *Client) resolveFfmpeg(ctx context.Context) (string, bool, error) {
+	return c.resolveBinary(ctx, "FFmpeg", &c.ffmpegPath, c.GetFfmpegPath,
+		func(ctx context.Context) (string, error) {
+			return c.fetchOrExtract(ctx, "ffmpeg")
+		}, ErrFfmpegNotFound)
+}
+
+// Result of resolving a binary.
+type resolved struct {
+	path       string
+	downloaded bool
+}
+
+// Get a binary's cached path, or find it, or download it if not yet.
+//
+// The shared download isn't cancelled by ctx, which only stops the wait of
+// this caller, so one caller giving up doesn't fail the others.
+func (c *Client) resolveBinary(
+	ctx context.Context,
+	name string,
+	cache *pathCache,
+	find func() string,
+	fetch func(ctx context.Context) (string, error),
+	errNotFound error,
+) (string, bool, error) {
 	// return if cached
-	if ffmpegPath != "" {
-		return ffmpegPath, nil
-	}
-	// try if ffmpeg exists
-	if path := GetFfmpegPath(); path != "" {
-		ffmpegPath = path
-		return path, nil
-	}
-	// download ffmpeg
-	os.Stdout.WriteString("FFmpeg downloading...\n")
-	if _, err := FetchFfmpeg(); err != nil {
-		os.Stderr.WriteString("FFmpeg download faild\n")
-		return "", err
+	if path := cache.get(); path != "" {
+		return path, false, nil
 	}
-	// re-get the path to ensure the downloaded ffmpeg is ok
-	if path := GetFfmpegPath(); path != "" {
-		ffmpegPath = path
-		return path, nil
+	flightCtx := context.WithoutCancel(ctx)
+	// concurrent callers share a single resolution and download
+	ch := c.resolveGroup.DoChan(name, func() (any, error) {
+		// a previous flight may have finished meanwhile
+		if path := cache.get(); path != "" {
+			return resolved{path, false}, nil
+		}
+		// try if the binary exists
+		if path := find(); path != "" {
+			cache.set(path)
+			return resolved{path, false}, nil
+		}
+		// download the binary
+		c.logInfo(name + " downloading...")
+		if _, err := fetch(flightCtx); err != nil {
+			c.logError(name+" download faild", "err", err)
+			return resolved{}, err
+		}
+		// re-get the path to ensure the downloaded binary is ok
+		if path := find(); path != "" {
+			cache.set(path)
+			return resolved{path, true}, nil
+		}
+		return resolved{}, errNotFound
+	})
+	select {
+	case r := <-ch:
+		return r.Val.(resolved).path, r.Val.(resolved).downloaded, r.Err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+var errVersionParseFailed = errors.New("cannot parse ffmpeg version")
+
+// Get the version of the resolved FFmpeg.
+//
+// Returns:
+//
+//	string: version token, e.g. "n7.0.1"
+//	error: error
+func FfmpegVersion() (string, error) {
+	return defaultClient.FfmpegVersion()
+}
+
+// Like FfmpegVersion, using the client's settings.
+func (c *Client) FfmpegVersion() (string, error) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve ffmpeg: %w", err)
+	}
+	return ffmpegVersionAt(ffmpeg)
+}
+
+// Get the version of the ffmpeg at the path.
+func ffmpegVersionAt(ffmpeg string) (string, error) {
+	out, err := runVersion(ffmpeg)
+	if err != nil {
+		return "", fmt.Errorf("cannot run %s: %w", ffmpeg, err)
+	}
+	// parse the first line, e.g. "ffmpeg version n7.0.1 Copyright ..."
+	line, _, _ := strings.Cut(string(out), "\n")
+	const prefix = "ffmpeg version "
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf("%w: %q", errVersionParseFailed, line)
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, prefix))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: %q", errVersionParseFailed, line)
+	}
+	return fields[0], nil
+}
+
+// Major version of a ffmpeg binary, ok is false if unknown.
+type majorVersion struct {
+	major int
+	ok    bool
+}
+
+// Get the major version of the resolved FFmpeg, false if unknown.
+//
+// It's cached per path to not run ffmpeg -version for every call.
+func (c *Client) ffmpegMajor() (int, bool) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return 0, false
+	}
+	c.lock.RLock()
+	v, ok := c.ffmpegMajors[ffmpeg]
+	c.lock.RUnlock()
+	if ok {
+		return v.major, v.ok
+	}
+	version, err := ffmpegVersionAt(ffmpeg)
+	if err != nil && !errors.Is(err, errVersionParseFailed) {
+		// may be transient, try again next time
+		return 0, false
+	}
+	v.major, v.ok = ffmpegMajorVersion(version)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ffmpegMajors == nil {
+		c.ffmpegMajors = map[string]majorVersion{}
+	}
+	c.ffmpegMajors[ffmpeg] = v
+	return v.major, v.ok
+}
+
+// Get the major version of a FfmpegVersion, e.g. 7 of "n7.0.1" or
+// "7.0.1-static", false for git builds like "N-113000-g1234abcd".
+func ffmpegMajorVersion(version string) (int, bool) {
+	version = strings.TrimPrefix(version, "n")
+	end := strings.IndexFunc(version, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if end < 0 {
+		end = len(version)
+	}
+	major, err := strconv.Atoi(version[:end])
+	if err != nil {
+		return 0, false
 	}
-	return "", errFfmpegNotFound
+	return major, true
 }
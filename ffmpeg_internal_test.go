@@ -0,0 +1,107 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestUserBinDir(t *testing.T) {
+	t.Setenv("XDG_BIN_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	home := t.TempDir()
+	homeDir := func() (string, error) { return home, nil }
+	if got, want := userBinDir("linux", homeDir),
+		filepath.Join(home, ".local", "bin"); got != want {
+		t.Errorf("userBinDir(linux) = %q, want %q", got, want)
+	}
+	if got, want := userBinDir("windows", homeDir),
+		filepath.Join(home, "AppData", "Local", "Programs"); got != want {
+		t.Errorf("userBinDir(windows) = %q, want %q", got, want)
+	}
+	// the executable's dir, not the executable itself
+	noHome := func() (string, error) { return "", errors.New("no home") }
+	for _, goos := range []string{"linux", "windows"} {
+		if got, want := userBinDir(goos, noHome), getExecDir(); got != want {
+			t.Errorf("userBinDir(%s) without home = %q, want %q",
+				goos, got, want)
+		}
+	}
+}
+
+// Write a fake ffmpeg answering -version to the dir.
+func writeFakeFfmpeg(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	script := []byte("#!/bin/sh\necho ffmpeg\n")
+	if err := os.WriteFile(path, script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindBinaryPathFirst(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+	t.Setenv("FFMPEG_PATH", "")
+	installDir, pathDir := t.TempDir(), t.TempDir()
+	t.Setenv("PATH", pathDir)
+	c := New(WithInstallDir(installDir))
+	// a stale variant-named download and a plain system binary
+	stale := writeFakeFfmpeg(t, installDir, c.getInstalledName("ffmpeg"))
+	system := writeFakeFfmpeg(t, pathDir, c.getBinaryName("ffmpeg", ""))
+	if got := c.GetFfmpegPath(); got != stale {
+		t.Errorf("SearchLocalFirst GetFfmpegPath() = %q, want %q", got, stale)
+	}
+	c.set(WithSearchOrder(SearchPathFirst))
+	if got := c.GetFfmpegPath(); got != system {
+		t.Errorf("SearchPathFirst GetFfmpegPath() = %q, want %q", got, system)
+	}
+}
+
+func TestBinaryNaming(t *testing.T) {
+	c := New(WithBinaryNaming("myffmpeg", "-"))
+	ext := ""
+	if runtime.GOOS == "windows" {
+		ext = ".exe"
+	}
+	for _, tc := range []struct {
+		base, variant string
+		want          string
+	}{
+		{"ffmpeg", "linux_x86_64", "myffmpeg-linux_x86_64"},
+		{"ffmpeg", "", "myffmpeg"},
+		// only the base name of ffmpeg is replaced
+		{"ffprobe", "linux_x86_64", "ffprobe-linux_x86_64"},
+		{"ffprobe", "", "ffprobe"},
+	} {
+		if got := c.getBinaryName(tc.base, tc.variant); got != tc.want+ext {
+			t.Errorf("getBinaryName(%q, %q) = %q, want %q",
+				tc.base, tc.variant, got, tc.want+ext)
+		}
+	}
+}
+
+func TestFfmpegMajorVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		major   int
+		ok      bool
+	}{
+		{"n7.0.1", 7, true},
+		{"7.0.1-static", 7, true},
+		{"4.4.2-0ubuntu0.22.04.1", 4, true},
+		{"5.1", 5, true},
+		{"N-113000-g1234abcd", 0, false},
+		{"", 0, false},
+	} {
+		major, ok := ffmpegMajorVersion(tc.version)
+		if major != tc.major || ok != tc.ok {
+			t.Errorf("ffmpegMajorVersion(%q) = %d, %v, want %d, %v",
+				tc.version, major, ok, tc.major, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"errors"
+)
+
+// Get path of FFprobe.
+//
+// The FFPROBE_PATH environment variable is checked first, then the same
+// dirs as GetFfmpegPath.
+//
+// Returns:
+//
+//	string: path of the executable
+func GetFfprobePath() string {
+	return defaultClient.GetFfprobePath()
+}
+
+// Like GetFfprobePath, using the client's settings.
+func (c *Client) GetFfprobePath() string {
+	return c.findBinary("ffprobe", "FFPROBE_PATH")
+}
+
+// Download FFprobe to the install directory.
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func FetchFfprobe() (string, error) {
+	return defaultClient.FetchFfprobe()
+}
+
+// Like FetchFfprobe, using the client's settings.
+func (c *Client) FetchFfprobe() (string, error) {
+	return c.FetchFfprobeContext(context.Background())
+}
+
+// Download FFprobe to the install directory, aborting when ctx is done.
+//
+// Args:
+//
+//	ctx: context to cancel the download
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func FetchFfprobeContext(ctx context.Context) (string, error) {
+	return defaultClient.FetchFfprobeContext(ctx)
+}
+
+// Like FetchFfprobeContext, using the client's settings.
+func (c *Client) FetchFfprobeContext(ctx context.Context) (string, error) {
+	return c.fetchBinary(ctx, "ffprobe")
+}
+
+// no valid FFprobe is found, even after downloading
+var ErrFfprobeNotFound = errors.New("cannot find executable ffprobe")
+
+// Get FFprobe's path or download it if not yet.
+//
+// The binary registered by SetEmbeddedFS is extracted if the download
+// fails.
+//
+// Returns:
+//
+//	string: path on success
+//	error: error
+func Ffprobe() (string, error) {
+	return defaultClient.Ffprobe()
+}
+
+// Like Ffprobe, using the client's settings.
+func (c *Client) Ffprobe() (string, error) {
+	path, _, err := c.FfprobeWithStatus()
+	return path, err
+}
+
+// Get FFprobe's path or download it if not yet, telling if downloaded.
+//
+// Concurrent callers sharing the same download all report it.
+//
+// Returns:
+//
+//	string: path on success
+//	bool: whether FFprobe was downloaded by this call
+//	error: error
+func FfprobeWithStatus() (string, bool, error) {
+	return defaultClient.FfprobeWithStatus()
+}
+
+// Like FfprobeWithStatus, using the client's settings.
+func (c *Client) FfprobeWithStatus() (string, bool, error) {
+	return c.resolveBinary(context.Background(),
+		"FFprobe", &c.ffprobePath, c.GetFfprobePath,
+		func(ctx context.Context) (string, error) {
+			return c.fetchOrExtract(ctx, "ffprobe")
+		}, ErrFfprobeNotFound)
+}
@@ -0,0 +1,189 @@
+package ffmpeghelper
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// Get a function reading the luminance of the image's pixels.
+func lumaFunc(img image.Image) func(x, y int) uint8 {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		// decoded jpegs, read the luma plane directly
+		return func(x, y int) uint8 {
+			return img.Y[img.YOffset(x, y)]
+		}
+	case *image.Gray:
+		return func(x, y int) uint8 {
+			return img.GrayAt(x, y).Y
+		}
+	}
+	return func(x, y int) uint8 {
+		return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+	}
+}
+
+// fraction of dark pixels for a frame to be black, like blackdetect's pic_th
+const blackPixelRatio = 0.98
+
+// Check if a frame is predominantly black, e.g. a slate of a dead stream,
+// to skip it before scanning.
+//
+// Args:
+//
+//	img: the frame
+//	threshold: max luminance of a dark pixel from 0 to 1, e.g. 0.1
+//
+// Returns:
+//
+//	bool: whether at least 98% of the pixels are dark
+func IsFrameBlack(img image.Image, threshold float64) bool {
+	b := img.Bounds()
+	total := b.Dx() * b.Dy()
+	if total == 0 {
+		return true
+	}
+	limit := uint8(min(max(threshold, 0), 1) * 255)
+	luma := lumaFunc(img)
+	dark := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if luma(x, y) <= limit {
+				dark++
+			}
+		}
+	}
+	return float64(dark) >= blackPixelRatio*float64(total)
+}
+
+// Get a perceptual hash of a frame to tell if it has changed.
+//
+// It's a dHash comparing the brightness of neighboring cells of a 9x8
+// grid, which is stable across scaling and recompression and deterministic
+// across runs.
+//
+// Args:
+//
+//	img: the frame
+//
+// Returns:
+//
+//	uint64: the hash, compare with HammingDistance
+func FrameHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	luma := lumaFunc(img)
+	// average the luminance of each cell
+	var cells [rows][cols]uint64
+	for r := range rows {
+		y0 := b.Min.Y + r*b.Dy()/rows
+		y1 := max(b.Min.Y+(r+1)*b.Dy()/rows, y0+1)
+		for c := range cols {
+			x0 := b.Min.X + c*b.Dx()/cols
+			x1 := max(b.Min.X+(c+1)*b.Dx()/cols, x0+1)
+			var sum, n uint64
+			for y := y0; y < min(y1, b.Max.Y); y++ {
+				for x := x0; x < min(x1, b.Max.X); x++ {
+					sum += uint64(luma(x, y))
+					n++
+				}
+			}
+			if n > 0 {
+				cells[r][c] = sum / n
+			}
+		}
+	}
+	// a bit per cell brighter than its right neighbor
+	var hash uint64
+	for r := range rows {
+		for c := range cols - 1 {
+			hash <<= 1
+			if cells[r][c] > cells[r][c+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Count the differing bits of two hashes of FrameHash.
+//
+// Args:
+//
+//	a: a hash
+//	b: another hash
+//
+// Returns:
+//
+//	int: 0 for identical frames, up to about 10 for similar ones
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Rotate an image clockwise, e.g. by MediaInfo.Rotation to display a
+// mobile-captured frame upright.
+//
+// Frames of files are rotated by ffmpeg already, this is for streams piped
+// without the rotation metadata.
+//
+// Args:
+//
+//	img: the image
+//	degrees: clockwise degrees, rounded to a multiple of 90
+//
+// Returns:
+//
+//	image.Image: the rotated image, img itself for 0
+func Rotate(img image.Image, degrees int) image.Image {
+	r := normalizeRotation(float64(degrees))
+	if r == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out *image.RGBA
+	if r == 180 {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	} else {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch r {
+			case 90:
+				out.Set(h-1-y, x, c)
+			case 180:
+				out.Set(w-1-x, h-1-y, c)
+			case 270:
+				out.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return out
+}
+
+// Flip an image horizontally, e.g. a frame of a mirrored camera.
+//
+// Args:
+//
+//	img: the image
+//
+// Returns:
+//
+//	image.Image: the flipped image
+func FlipHorizontal(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			out.Set(w-1-x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
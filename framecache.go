@@ -0,0 +1,107 @@
+package ffmpeghelper
+
+import (
+	"container/list"
+	"image"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LRU cache of extracted frames by stream url, expiring after a TTL.
+type frameCache struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	// most recently used at the front
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type frameCacheEntry struct {
+	key     string
+	img     image.Image
+	expires time.Time
+}
+
+func newFrameCache(ttl time.Duration, maxEntries int) *frameCache {
+	return &frameCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Get the unexpired frame of the key.
+func (c *frameCache) get(key string) (image.Image, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*frameCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.img, true
+}
+
+// Store the frame of the key, evicting the least recently used one if
+// full.
+func (c *frameCache) put(key string, img image.Image) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*frameCacheEntry)
+		entry.img, entry.expires = img, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&frameCacheEntry{key, img, expires})
+	for c.order.Len() > c.maxEntries {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*frameCacheEntry).key)
+	}
+}
+
+// Normalize a stream url as a cache key, the scheme and host are case
+// insensitive and the fragment isn't sent.
+func frameCacheKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// Cache the frames of M3U8GetImage by url for the ttl, keeping up to
+// maxEntries streams.
+//
+// Repeated calls within the ttl return the same image without running
+// ffmpeg, so it must not be modified. A ttl or maxEntries <= 0 disables the
+// cache, which is the default.
+//
+// Args:
+//
+//	ttl: time a frame is reused
+//	maxEntries: max number of cached streams
+func SetFrameCache(ttl time.Duration, maxEntries int) {
+	defaultClient.set(WithFrameCache(ttl, maxEntries))
+}
+
+func (c *Client) getFrameCache() *frameCache {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.frameCache
+}
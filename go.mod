@@ -2,9 +2,13 @@ module github.com/StellarForager/FFmpeg-helper
 
 go 1.24.7
 
-require github.com/makiuchi-d/gozxing v0.1.1
+require (
+	github.com/makiuchi-d/gozxing v0.1.1
+	golang.org/x/image v0.36.0
+	golang.org/x/sync v0.19.0
+)
 
 require (
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/text v0.34.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
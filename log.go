@@ -0,0 +1,50 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// Set the logger for messages like download notices.
+//
+// By default the messages are written as plain lines to os.Stdout and
+// os.Stderr. Use slog.New(slog.DiscardHandler) to silence them.
+//
+// Args:
+//
+//	l: the logger, or nil to restore the default
+func SetLogger(l *slog.Logger) {
+	defaultClient.set(WithLogger(l))
+}
+
+func (c *Client) getLogger() *slog.Logger {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.logger
+}
+
+func (c *Client) logMsg(level slog.Level, msg string, args ...any) {
+	if l := c.getLogger(); l != nil {
+		l.Log(context.Background(), level, msg, args...)
+		return
+	}
+	// keep the plain lines without a logger
+	if level >= slog.LevelWarn {
+		os.Stderr.WriteString(msg + "\n")
+	} else {
+		os.Stdout.WriteString(msg + "\n")
+	}
+}
+
+func (c *Client) logInfo(msg string, args ...any) {
+	c.logMsg(slog.LevelInfo, msg, args...)
+}
+
+func (c *Client) logWarn(msg string, args ...any) {
+	c.logMsg(slog.LevelWarn, msg, args...)
+}
+
+func (c *Client) logError(msg string, args ...any) {
+	c.logMsg(slog.LevelError, msg, args...)
+}
@@ -0,0 +1,96 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// Loudness of the audio measured by EBU R128.
+type LoudnessInfo struct {
+	// integrated loudness in LUFS, -Inf if silent
+	Integrated float64
+	// true peak in dBTP
+	TruePeak float64
+	// loudness range in LU
+	LRA float64
+	// gating threshold in LUFS
+	Threshold float64
+}
+
+// Summary printed by the loudnorm filter with print_format=json.
+type loudnormOutput struct {
+	InputI      string `json:"input_i"`
+	InputTP     string `json:"input_tp"`
+	InputLRA    string `json:"input_lra"`
+	InputThresh string `json:"input_thresh"`
+}
+
+var errNoLoudness = errors.New("no loudness summary in the ffmpeg output")
+
+// Measure the loudness of the first audio stream of a media, e.g. for
+// audio QC.
+//
+// The whole input is decoded by the loudnorm filter in analysis mode, so a
+// live stream must be bounded, e.g. by Trim first.
+//
+// Args:
+//
+//	input: url or local path of the media
+//
+// Returns:
+//
+//	*LoudnessInfo: the loudness
+//	error: error
+func AnalyzeLoudness(input string) (*LoudnessInfo, error) {
+	return defaultClient.AnalyzeLoudness(input)
+}
+
+// Like AnalyzeLoudness, using the client's settings.
+func (c *Client) AnalyzeLoudness(input string) (*LoudnessInfo, error) {
+	_, stderr, err := c.RunFfmpeg(context.Background(),
+		"-v", "info", // the summary is logged at the info level
+		"-nostats", "-hide_banner",
+		"-i", input,
+		"-vn", // no video
+		"-af", "loudnorm=print_format=json",
+		"-f", "null", "-", // discard the output
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseLoudnorm(stderr)
+}
+
+// Parse the json summary at the end of the loudnorm log.
+func parseLoudnorm(stderr []byte) (*LoudnessInfo, error) {
+	start := bytes.LastIndexByte(stderr, '{')
+	end := bytes.LastIndexByte(stderr, '}')
+	if start < 0 || end < start {
+		return nil, errNoLoudness
+	}
+	var lo loudnormOutput
+	if err := json.Unmarshal(stderr[start:end+1], &lo); err != nil {
+		return nil, err
+	}
+	info := &LoudnessInfo{}
+	for _, f := range []struct {
+		s string
+		v *float64
+	}{
+		{lo.InputI, &info.Integrated},
+		{lo.InputTP, &info.TruePeak},
+		{lo.InputLRA, &info.LRA},
+		{lo.InputThresh, &info.Threshold},
+	} {
+		// "-inf" of silence parses too
+		v, err := strconv.ParseFloat(f.s, 64)
+		if err != nil {
+			return nil, errNoLoudness
+		}
+		*f.v = v
+	}
+	return info, nil
+}
@@ -0,0 +1,80 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// stderr of ffmpeg -v info with the loudnorm filter, ids shortened
+const loudnormLog = `Input #0, wav, from 'tone.wav':
+  Duration: 00:00:10.00, bitrate: 1536 kb/s
+  Stream #0:0: Audio: pcm_s16le, 48000 Hz, stereo, s16, 1536 kb/s
+Stream mapping:
+  Stream #0:0 -> #0:0 (pcm_s16le (native) -> pcm_s16le (native))
+[Parsed_loudnorm_0 @ 0x5581e5b2c440]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.10",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.80",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`
+
+func TestParseLoudnorm(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stderr string
+		want   *LoudnessInfo
+	}{
+		{"summary", loudnormLog, &LoudnessInfo{
+			Integrated: -27.61, TruePeak: -4.47, LRA: 18.10, Threshold: -39.20,
+		}},
+		// the muxer's stats after the summary
+		{"trailing noise", loudnormLog +
+			"[out#0/null @ 0x5581e5b31c80] video:0kB audio:1875kB " +
+			"subtitle:0kB other streams:0kB global headers:0kB " +
+			"muxing overhead: unknown\n" +
+			"size=N/A time=00:00:10.00 bitrate=N/A speed= 412x\n",
+			&LoudnessInfo{
+				Integrated: -27.61, TruePeak: -4.47, LRA: 18.10,
+				Threshold: -39.20,
+			}},
+		{"silence", `{
+	"input_i" : "-inf",
+	"input_tp" : "-inf",
+	"input_lra" : "0.00",
+	"input_thresh" : "-70.00"
+}`, &LoudnessInfo{
+			Integrated: math.Inf(-1), TruePeak: math.Inf(-1), LRA: 0,
+			Threshold: -70,
+		}},
+	} {
+		got, err := parseLoudnorm([]byte(tc.stderr))
+		if err != nil {
+			t.Fatalf("parseLoudnorm(%s) err: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseLoudnorm(%s) = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+	for _, stderr := range []string{
+		"",
+		// no audio stream, the filter never prints a summary
+		"Output file #0 does not contain any stream\n",
+		`{"input_i" : "nan?"}`,
+	} {
+		if _, err := parseLoudnorm([]byte(stderr)); !errors.Is(
+			err, errNoLoudness) {
+			t.Errorf("parseLoudnorm(%q) err = %v, want %v",
+				stderr, err, errNoLoudness)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parsed m3u8 playlist.
+type playlist struct {
+	segments []segment
+	// variant streams of a master playlist
+	variants []Variant
+	// whether #EXT-X-ENDLIST closes it, i.e. not live
+	endList bool
+}
+
+// Media segment of a playlist.
+type segment struct {
+	uri string
+	// media sequence number
+	seq int
+	// from #EXTINF
+	duration time.Duration
+	// nil if not encrypted
+	key *segmentKey
+}
+
+// Encryption key of segments from #EXT-X-KEY.
+type segmentKey struct {
+	method string
+	uri    string
+	// nil to derive from the sequence number
+	iv []byte
+}
+
+// Resolve the uris of the segment in the playlist at base.
+func (s *segment) resolve(base string) error {
+	uri, err := resolveUri(base, s.uri)
+	if err != nil {
+		return err
+	}
+	s.uri = uri
+	if s.key != nil {
+		uri, err := resolveUri(base, s.key.uri)
+		if err != nil {
+			return err
+		}
+		key := *s.key
+		key.uri = uri
+		s.key = &key
+	}
+	return nil
+}
+
+var (
+	errKeyUnsupported = errors.New("unsupported segment encryption")
+	errKeyInvalid     = errors.New("invalid segment key")
+)
+
+// Decrypt the AES-128 encrypted segment data.
+func (c *Client) decryptSegment(ctx context.Context,
+	s *segment, data []byte, header http.Header) ([]byte, error) {
+	if s.key.method != "AES-128" {
+		return nil, fmt.Errorf("%w: %s", errKeyUnsupported, s.key.method)
+	}
+	// fetch the key
+	res, err := c.streamGet(ctx, s.key.uri, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, ErrTsReadFailed
+	}
+	key, err := io.ReadAll(io.LimitReader(res.Body, aes.BlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != aes.BlockSize {
+		return nil, errKeyInvalid
+	}
+	iv := s.key.iv
+	if iv == nil {
+		// the sequence number as a big-endian 128-bit integer
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(s.seq))
+	}
+	return decryptAes128(data, key, iv)
+}
+
+// Decrypt AES-128-CBC data with PKCS7 padding.
+func decryptAes128(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrTsReadFailed
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	// strip the padding
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errKeyInvalid
+	}
+	return out[:len(out)-pad], nil
+}
+
+// Parse the attributes of #EXT-X-KEY, nil for METHOD=NONE.
+func parseKey(attrs map[string]string) *segmentKey {
+	if attrs["METHOD"] == "NONE" {
+		return nil
+	}
+	key := &segmentKey{method: attrs["METHOD"], uri: attrs["URI"]}
+	// e.g. IV=0x9c7db8778570d05c3177c349fd9236aa
+	if v := attrs["IV"]; len(v) > 2 {
+		if iv, err := hex.DecodeString(v[2:]); err == nil &&
+			len(iv) == aes.BlockSize {
+			key.iv = iv
+		}
+	}
+	return key
+}
+
+// Variant stream of a HLS master playlist.
+type Variant struct {
+	URI string
+	// peak bits per second
+	Bandwidth int
+	// e.g. "1920x1080", "" if unknown
+	Resolution string
+	// e.g. "avc1.4d401f,mp4a.40.2", "" if unknown
+	Codecs string
+}
+
+// Parse a m3u8 playlist, lines not starting with '#' are segment uris, or
+// variant uris following #EXT-X-STREAM-INF in a master playlist.
+func parsePlaylist(body string) *playlist {
+	pl := &playlist{}
+	var streamInf map[string]string
+	var key *segmentKey
+	var duration time.Duration
+	seq := 0
+	for _, line := range strings.Split(body, "\n") {
+		// handle both \n and \r\n
+		line = strings.TrimSpace(line)
+		if attrs, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:"); ok {
+			streamInf = parseAttributes(attrs)
+			continue
+		}
+		if attrs, ok := strings.CutPrefix(line, "#EXT-X-KEY:"); ok {
+			key = parseKey(parseAttributes(attrs))
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"); ok {
+			seq, _ = strconv.Atoi(v)
+			continue
+		}
+		if line == "#EXT-X-ENDLIST" {
+			pl.endList = true
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			// e.g. "#EXTINF:9.009," or "#EXTINF:10,title"
+			v, _, _ = strings.Cut(v, ",")
+			if sec, err := strconv.ParseFloat(v, 64); err == nil {
+				duration = time.Duration(sec * float64(time.Second))
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			// blank lines, other tags and comments
+			continue
+		}
+		if streamInf != nil {
+			bandwidth, _ := strconv.Atoi(streamInf["BANDWIDTH"])
+			pl.variants = append(pl.variants, Variant{
+				URI:        line,
+				Bandwidth:  bandwidth,
+				Resolution: streamInf["RESOLUTION"],
+				Codecs:     streamInf["CODECS"],
+			})
+			streamInf = nil
+			continue
+		}
+		pl.segments = append(pl.segments, segment{
+			uri: line, seq: seq, duration: duration, key: key,
+		})
+		seq++
+		duration = 0
+	}
+	return pl
+}
+
+// Parse an attribute list like `BANDWIDTH=1280000,CODECS="a,b"`.
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			// quoted strings may contain commas
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(key)] = value
+		s = rest
+	}
+	return attrs
+}
+
+// Select the variant with the lowest bandwidth, enough for a frame.
+func lowestBandwidth(variants []Variant) int {
+	best := 0
+	for i, v := range variants {
+		if v.Bandwidth < variants[best].Bandwidth {
+			best = i
+		}
+	}
+	return best
+}
+
+// Set how a variant is selected from HLS master playlists.
+//
+// Args:
+//
+//	fn: returns the index of the variant to use, or nil to restore the
+//	default of the lowest bandwidth
+func SetVariantSelector(fn func(variants []Variant) int) {
+	defaultClient.set(WithVariantSelector(fn))
+}
+
+func (c *Client) getVariantSelector() func(variants []Variant) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.variantSelector
+}
+
+var errVariantInvalid = errors.New("invalid variant selected")
+
+// Fetch and parse a m3u8 playlist.
+func (c *Client) fetchPlaylist(
+	ctx context.Context, url string, header http.Header) (*playlist, error) {
+	res, err := c.streamGet(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, ErrTsFetchFailed
+	}
+	body, _ := io.ReadAll(res.Body)
+	return parsePlaylist(string(body)), nil
+}
+
+// Fetch a media playlist, following a variant if url is a master playlist.
+//
+// Returns the media playlist and its url.
+func (c *Client) fetchMediaPlaylist(
+	ctx context.Context, url string, header http.Header,
+) (*playlist, string, error) {
+	pl, err := c.fetchPlaylist(ctx, url, header)
+	if err != nil || len(pl.variants) == 0 {
+		return pl, url, err
+	}
+	// master playlist
+	i := c.getVariantSelector()(pl.variants)
+	if i < 0 || i >= len(pl.variants) {
+		return nil, "", fmt.Errorf("%w: %d", errVariantInvalid, i)
+	}
+	url, err = resolveUri(url, pl.variants[i].URI)
+	if err != nil {
+		return nil, "", err
+	}
+	pl, err = c.fetchPlaylist(ctx, url, header)
+	return pl, url, err
+}
+
+// Get the duration of a M3U8 stream by summing the #EXTINF durations of its
+// media playlist, without running ffprobe.
+//
+// A live playlist without #EXT-X-ENDLIST gives the duration of its rolling
+// window of segments.
+//
+// Args:
+//
+//	url: url of the stream
+//
+// Returns:
+//
+//	time.Duration: the duration
+//	bool: whether the stream is live
+//	error: error
+func M3U8Duration(url string) (time.Duration, bool, error) {
+	return defaultClient.M3U8Duration(url)
+}
+
+// Like M3U8Duration, using the client's settings.
+func (c *Client) M3U8Duration(url string) (time.Duration, bool, error) {
+	ctx, cancel := withTimeout(context.Background(), c.getStreamTimeout())
+	defer cancel()
+	pl, _, err := c.fetchMediaPlaylist(ctx, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(pl.segments) == 0 {
+		return 0, false, ErrTsParseFailed
+	}
+	var d time.Duration
+	for _, s := range pl.segments {
+		d += s.duration
+	}
+	return d, !pl.endList, nil
+}
+
+// Resolve a relative, root-relative or absolute uri in the playlist at
+// base.
+func resolveUri(base, uri string) (string, error) {
+	b, err := neturl.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	ref, err := neturl.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,270 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolveUri(t *testing.T) {
+	const base = "https://cdn.example.com/live/stream/index.m3u8"
+	for _, c := range []struct {
+		uri  string
+		want string
+	}{
+		// relative
+		{"seg1.ts", "https://cdn.example.com/live/stream/seg1.ts"},
+		{"../seg1.ts?t=1", "https://cdn.example.com/live/seg1.ts?t=1"},
+		// root-relative
+		{"/other/seg1.ts", "https://cdn.example.com/other/seg1.ts"},
+		// absolute
+		{"https://edge.example.net/seg1.ts", "https://edge.example.net/seg1.ts"},
+	} {
+		got, err := resolveUri(base, c.uri)
+		if err != nil {
+			t.Fatalf("resolveUri(%q) err: %v", c.uri, err)
+		}
+		if got != c.want {
+			t.Errorf("resolveUri(%q) = %q, want %q", c.uri, got, c.want)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want map[string]string
+	}{
+		{"BANDWIDTH=1280000", map[string]string{"BANDWIDTH": "1280000"}},
+		// quoted strings with commas
+		{`BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=640x360`,
+			map[string]string{
+				"BANDWIDTH":  "1280000",
+				"CODECS":     "avc1.4d401f,mp4a.40.2",
+				"RESOLUTION": "640x360",
+			}},
+		{`METHOD=AES-128,URI="https://keys.example.com/k?a=1,b=2",IV=0x01`,
+			map[string]string{
+				"METHOD": "AES-128",
+				"URI":    "https://keys.example.com/k?a=1,b=2",
+				"IV":     "0x01",
+			}},
+		// unterminated quote runs to the end
+		{`URI="key.bin`, map[string]string{"URI": "key.bin"}},
+		{"", map[string]string{}},
+	} {
+		if got := parseAttributes(tc.s); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseAttributes(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	iv := []byte{0x9c, 0x7d, 0xb8, 0x77, 0x85, 0x70, 0xd0, 0x5c,
+		0x31, 0x77, 0xc3, 0x49, 0xfd, 0x92, 0x36, 0xaa}
+	for _, tc := range []struct {
+		attrs map[string]string
+		want  *segmentKey
+	}{
+		{map[string]string{"METHOD": "NONE"}, nil},
+		{map[string]string{"METHOD": "AES-128", "URI": "key.bin"},
+			&segmentKey{method: "AES-128", uri: "key.bin"}},
+		{map[string]string{"METHOD": "AES-128", "URI": "key.bin",
+			"IV": "0x9c7db8778570d05c3177c349fd9236aa"},
+			&segmentKey{method: "AES-128", uri: "key.bin", iv: iv}},
+		// a malformed IV is derived from the sequence number instead
+		{map[string]string{"METHOD": "AES-128", "URI": "key.bin", "IV": "0x12"},
+			&segmentKey{method: "AES-128", uri: "key.bin"}},
+	} {
+		if got := parseKey(tc.attrs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseKey(%v) = %+v, want %+v", tc.attrs, got, tc.want)
+		}
+	}
+}
+
+func TestParsePlaylist(t *testing.T) {
+	key := &segmentKey{method: "AES-128", uri: "key.bin"}
+	for _, tc := range []struct {
+		name string
+		body string
+		want *playlist
+	}{
+		{"media", "#EXTM3U\n" +
+			"#EXT-X-MEDIA-SEQUENCE:7\n" +
+			"#EXTINF:9.009,\n" +
+			"seg7.ts\n" +
+			`#EXT-X-KEY:METHOD=AES-128,URI="key.bin"` + "\n" +
+			"#EXTINF:10,title\n" +
+			"seg8.ts\n" +
+			"#EXT-X-KEY:METHOD=NONE\n" +
+			"seg9.ts\n" +
+			"#EXT-X-ENDLIST\n",
+			&playlist{
+				segments: []segment{
+					{uri: "seg7.ts", seq: 7, duration: 9009 * time.Millisecond},
+					{uri: "seg8.ts", seq: 8, duration: 10 * time.Second, key: key},
+					{uri: "seg9.ts", seq: 9},
+				},
+				endList: true,
+			}},
+		{"crlf", "#EXTM3U\r\n#EXTINF:4,\r\nseg0.ts\r\n\r\n#EXTINF:4,\r\nseg1.ts\r\n",
+			&playlist{segments: []segment{
+				{uri: "seg0.ts", seq: 0, duration: 4 * time.Second},
+				{uri: "seg1.ts", seq: 1, duration: 4 * time.Second},
+			}}},
+		{"master", "#EXTM3U\r\n" +
+			`#EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",` +
+			"RESOLUTION=640x360\r\n" +
+			"low/index.m3u8\r\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=2560000\r\n" +
+			"high/index.m3u8\r\n",
+			&playlist{variants: []Variant{
+				{URI: "low/index.m3u8", Bandwidth: 1280000,
+					Resolution: "640x360", Codecs: "avc1.4d401f,mp4a.40.2"},
+				{URI: "high/index.m3u8", Bandwidth: 2560000},
+			}}},
+	} {
+		if got := parsePlaylist(tc.body); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsePlaylist(%s) = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Encrypt data with AES-128-CBC and PKCS7 padding like a HLS packager.
+func encryptAes128(t *testing.T, data, key, iv []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(bytes.Clone(data), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+func TestDecryptAes128(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	for _, plain := range [][]byte{
+		[]byte("ts"),
+		// a full block of padding
+		[]byte("exactly 16 bytes"),
+		bytes.Repeat([]byte{0x47}, 188),
+	} {
+		got, err := decryptAes128(encryptAes128(t, plain, key, iv), key, iv)
+		if err != nil {
+			t.Fatalf("decryptAes128(%d bytes) err: %v", len(plain), err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("decryptAes128(%d bytes) = %q, want %q",
+				len(plain), got, plain)
+		}
+	}
+	// not a multiple of the block size
+	if _, err := decryptAes128(make([]byte, 17), key, iv); err == nil {
+		t.Error("decryptAes128(17 bytes) err = nil")
+	}
+	// a zero padding byte
+	block, _ := aes.NewCipher(key)
+	data := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	if _, err := decryptAes128(data, key, iv); !errors.Is(err, errKeyInvalid) {
+		t.Errorf("decryptAes128(bad padding) err = %v, want %v",
+			err, errKeyInvalid)
+	}
+}
+
+func TestDecryptSegment(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(key)
+		}))
+	defer srv.Close()
+	plain := []byte("mpeg-ts payload")
+	// the sequence number as a big-endian 128-bit integer
+	seqIV := make([]byte, aes.BlockSize)
+	seqIV[15] = 5
+	explicitIV := []byte("fedcba9876543210")
+	for _, tc := range []struct {
+		name string
+		seg  segment
+		iv   []byte
+	}{
+		{"sequence iv", segment{seq: 5,
+			key: &segmentKey{method: "AES-128", uri: srv.URL}}, seqIV},
+		{"explicit iv", segment{seq: 5,
+			key: &segmentKey{method: "AES-128", uri: srv.URL, iv: explicitIV}},
+			explicitIV},
+	} {
+		data := encryptAes128(t, plain, key, tc.iv)
+		got, err := New().decryptSegment(context.Background(), &tc.seg, data, nil)
+		if err != nil {
+			t.Fatalf("decryptSegment(%s) err: %v", tc.name, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("decryptSegment(%s) = %q, want %q", tc.name, got, plain)
+		}
+	}
+	seg := segment{key: &segmentKey{method: "SAMPLE-AES", uri: srv.URL}}
+	if _, err := New().decryptSegment(context.Background(),
+		&seg, nil, nil); !errors.Is(err, errKeyUnsupported) {
+		t.Errorf("decryptSegment(SAMPLE-AES) err = %v, want %v",
+			err, errKeyUnsupported)
+	}
+}
+
+// Find ffmpeg in PATH for the tests running it, skipping without one.
+func lookFfmpeg(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found in PATH")
+	}
+	return path
+}
+
+func TestM3U8GetImageHevc(t *testing.T) {
+	ffmpeg := lookFfmpeg(t)
+	// use it instead of downloading one
+	t.Setenv("FFMPEG_PATH", ffmpeg)
+	// a 1s HEVC segment of a test pattern
+	ts, err := exec.Command(ffmpeg, "-v", "error",
+		"-f", "lavfi", "-i", "testsrc=size=320x240:rate=25:duration=1",
+		"-c:v", "libx265", "-pix_fmt", "yuv420p",
+		"-f", "mpegts", "-").Output()
+	if err != nil || len(ts) == 0 {
+		t.Skipf("cannot encode hevc: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/index.m3u8":
+				io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:1\n"+
+					"#EXTINF:1,\nseg0.ts\n#EXT-X-ENDLIST\n")
+			case "/seg0.ts":
+				w.Write(ts)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer srv.Close()
+	img, err := New().M3U8GetImage(srv.URL + "/index.m3u8")
+	if err != nil {
+		t.Fatalf("M3U8GetImage(hevc) err: %v", err)
+	}
+	if got := img.Bounds().Size(); got.X != 320 || got.Y != 240 {
+		t.Errorf("M3U8GetImage(hevc) size = %v, want 320x240", got)
+	}
+}
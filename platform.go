@@ -0,0 +1,41 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Returned when the release has no binary for the platform.
+var ErrPlatformUnsupported = errors.New("unsupported platform")
+
+// release asset variants by GOOS/GOARCH
+var ffmpegVariants = map[string]string{
+	"linux/amd64":   "linux_x86_64",
+	"linux/386":     "linux_i686",
+	"linux/arm":     "linux_armhf",
+	"linux/arm64":   "linux_aarch64",
+	"linux/loong64": "linux_loongarch64",
+	"windows/amd64": "windows_x86_64",
+	"windows/386":   "windows_i686",
+	"windows/arm64": "windows_aarch64",
+	"darwin/amd64":  "darwin_x86_64",
+	"darwin/arm64":  "darwin_aarch64",
+	"android/arm":   "android_armv7a",
+	"android/arm64": "android_aarch64",
+	"android/amd64": "android_x86_64",
+	"android/386":   "android_i686",
+}
+
+// Get the release asset variant of the current platform.
+func getFfmpegVariant() (string, error) {
+	return ffmpegVariant(runtime.GOOS, runtime.GOARCH)
+}
+
+// Get the release asset variant of a platform, e.g. "linux_x86_64".
+func ffmpegVariant(goos, goarch string) (string, error) {
+	if v, ok := ffmpegVariants[goos+"/"+goarch]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s/%s", ErrPlatformUnsupported, goos, goarch)
+}
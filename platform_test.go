@@ -0,0 +1,36 @@
+package ffmpeghelper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFfmpegVariant(t *testing.T) {
+	for _, c := range []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"linux", "amd64", "linux_x86_64"},
+		{"linux", "arm", "linux_armhf"},
+		{"android", "arm", "android_armv7a"},
+		{"windows", "386", "windows_i686"},
+		{"darwin", "arm64", "darwin_aarch64"},
+	} {
+		got, err := ffmpegVariant(c.goos, c.goarch)
+		if err != nil {
+			t.Fatalf("ffmpegVariant(%q, %q) err: %v", c.goos, c.goarch, err)
+		}
+		if got != c.want {
+			t.Errorf("ffmpegVariant(%q, %q) = %q, want %q",
+				c.goos, c.goarch, got, c.want)
+		}
+	}
+	for _, p := range [][2]string{{"linux", "riscv64"}, {"linux", "mips"},
+		{"freebsd", "amd64"}} {
+		if _, err := ffmpegVariant(p[0], p[1]); !errors.Is(
+			err, ErrPlatformUnsupported) {
+			t.Errorf("ffmpegVariant(%q, %q) err = %v, want %v",
+				p[0], p[1], err, ErrPlatformUnsupported)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ffmpeghelper
+
+import (
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"golang.org/x/image/draw"
+)
+
+// Set the preprocessing of images before scanning barcodes.
+//
+// Preprocessed images are converted to grayscale and downscaled to fit in a
+// square of maxDimension, which is several times faster on 1080p frames and
+// often more reliable on large codes. Result points still refer to the
+// source image.
+//
+// The image functions like ImgScanQrcode use this setting, the stream and
+// video scans of a Client use its own, see WithScanPreprocess.
+//
+// Args:
+//
+//	maxDimension: max width and height, or 0 to scan the source image
+func SetScanPreprocess(maxDimension int) {
+	defaultClient.set(WithScanPreprocess(maxDimension))
+}
+
+func (c *Client) getScanMaxDimension() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.scanMaxDimension
+}
+
+// Build the bitmap to scan, preprocessed if maxDimension > 0, returning the
+// factor mapping its points back to img.
+func newBitmap(
+	img image.Image, maxDimension int) (*gozxing.BinaryBitmap, float64, error) {
+	scale := 1.0
+	if maxDimension > 0 {
+		img, scale = preprocess(img, maxDimension)
+	}
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	return bmp, scale, err
+}
+
+// Convert to grayscale and downscale to fit in a square of maxDimension.
+func preprocess(img image.Image, maxDimension int) (*image.Gray, float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := 1.0
+	if side := max(w, h); side > maxDimension {
+		scale = float64(side) / float64(maxDimension)
+		w = max(int(float64(w)/scale), 1)
+		h = max(int(float64(h)/scale), 1)
+	}
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(gray, gray.Bounds(), img, b, draw.Src, nil)
+	return gray, scale
+}
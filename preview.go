@@ -0,0 +1,229 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"strconv"
+	"time"
+)
+
+var errInvalidGrid = errors.New("invalid thumbnail grid")
+
+// width of each thumbnail in the sheet
+const thumbnailWidth = 320
+
+// Get a contact sheet tiling frames evenly sampled across a video.
+//
+// Thumbnails are scaled to 320px wide. The grid shrinks if the video has
+// fewer frames than rows*cols.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	rows: rows of the grid
+//	cols: columns of the grid
+//
+// Returns:
+//
+//	image.Image: the jpeg sheet
+//	error: error
+func ThumbnailSheet(input string, rows, cols int) (image.Image, error) {
+	return defaultClient.ThumbnailSheet(input, rows, cols)
+}
+
+// Like ThumbnailSheet, using the client's settings.
+func (c *Client) ThumbnailSheet(
+	input string, rows, cols int) (image.Image, error) {
+	if rows <= 0 || cols <= 0 {
+		return nil, fmt.Errorf("%w: %dx%d", errInvalidGrid, cols, rows)
+	}
+	info, err := c.Probe(input)
+	if err != nil {
+		return nil, err
+	}
+	// shrink the grid for short videos
+	n := rows * cols
+	frames := int(info.Duration.Seconds() * info.FrameRate)
+	if frames > 0 && frames < n {
+		n = frames
+		cols = min(cols, n)
+		rows = (n + cols - 1) / cols
+	}
+	// pick every step-th frame
+	step := 1
+	if frames > 0 {
+		step = max(int(math.Floor(float64(frames)/float64(n))), 1)
+	}
+	filter := fmt.Sprintf(
+		"select=not(mod(n\\,%d)),scale=%d:-2,tile=%dx%d",
+		step, thumbnailWidth, cols, rows)
+	out, _, err := c.RunFfmpeg(context.Background(),
+		"-v", c.getFfmpegLogLevel(), // see SetFfmpegLogLevel
+		"-i", input,
+		"-an",         // no audio
+		"-vf", filter, // sample and tile
+		"-vsync", "vfr", // drop the unselected frames
+		"-frames:v", "1", // 1 sheet
+		"-f", "image2pipe", "-c:v", "mjpeg", // output as jpeg
+		"-", // print to stdout
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errNoFrame
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+// Get jpeg images of the keyframes of a video, e.g. for scrubbing.
+//
+// Only keyframes are decoded, which is much faster than every frame.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	maxFrames: max number of keyframes
+//
+// Returns:
+//
+//	[]image.Image: the jpeg images in order
+//	error: error
+func ExtractKeyframes(input string, maxFrames int) ([]image.Image, error) {
+	return defaultClient.ExtractKeyframes(input, maxFrames)
+}
+
+// Like ExtractKeyframes, using the client's settings.
+func (c *Client) ExtractKeyframes(
+	input string, maxFrames int) ([]image.Image, error) {
+	if maxFrames <= 0 {
+		return nil, fmt.Errorf("invalid max frames: %d", maxFrames)
+	}
+	out, _, err := c.RunFfmpeg(context.Background(),
+		"-v", c.getFfmpegLogLevel(), // see SetFfmpegLogLevel
+		"-skip_frame", "nokey", // don't decode the other frames
+		"-i", input,
+		"-an", // no audio
+		"-vf", "select=eq(pict_type\\,I)",
+		"-vsync", "vfr", // drop the unselected frames
+		"-vframes", strconv.Itoa(maxFrames), // bound the memory
+		"-f", "image2pipe", "-c:v", "mjpeg", // output as jpegs
+		"-", // print to stdout
+	)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJpegs(out)
+}
+
+var errInvalidRange = errors.New("invalid time range")
+
+// Get a looping high quality GIF preview of a video.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	start: start of the preview
+//	duration: length of the preview
+//	fps: frames per second of the preview
+//
+// Returns:
+//
+//	[]byte: the encoded GIF
+//	error: error
+func AnimatedPreview(
+	input string, start, duration time.Duration, fps int) ([]byte, error) {
+	return defaultClient.AnimatedPreview(input, start, duration, fps)
+}
+
+// Like AnimatedPreview, using the client's settings.
+func (c *Client) AnimatedPreview(
+	input string, start, duration time.Duration, fps int) ([]byte, error) {
+	return c.AnimatedPreviewFormat(input, start, duration, fps, FormatGif)
+}
+
+// Get a looping preview of a video as an animated GIF or WebP.
+//
+// WebP is much smaller but requires ffmpeg built with libwebp.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	start: start of the preview
+//	duration: length of the preview
+//	fps: frames per second of the preview
+//	format: FormatGif or FormatWebp
+//
+// Returns:
+//
+//	[]byte: the encoded animation
+//	error: error
+func AnimatedPreviewFormat(
+	input string, start, duration time.Duration, fps int, format ImageFormat,
+) ([]byte, error) {
+	return defaultClient.AnimatedPreviewFormat(
+		input, start, duration, fps, format)
+}
+
+// Like AnimatedPreviewFormat, using the client's settings.
+func (c *Client) AnimatedPreviewFormat(
+	input string, start, duration time.Duration, fps int, format ImageFormat,
+) ([]byte, error) {
+	if fps <= 0 {
+		return nil, fmt.Errorf("invalid fps: %d", fps)
+	}
+	if err := c.checkRange(input, start, duration); err != nil {
+		return nil, err
+	}
+	filter := fmt.Sprintf("fps=%d,scale=%d:-2:flags=lanczos",
+		fps, thumbnailWidth)
+	var output []string
+	switch format {
+	case FormatGif:
+		// palettegen and paletteuse in one pass over the split input
+		filter += ",split[a][b];[a]palettegen[p];[b][p]paletteuse"
+		output = []string{"-loop", "0", "-f", "gif"}
+	case FormatWebp:
+		output = []string{"-c:v", "libwebp", "-loop", "0", "-f", "webp"}
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownFormat, string(format))
+	}
+	args := []string{
+		"-v", c.getFfmpegLogLevel(), // see SetFfmpegLogLevel
+		"-ss", formatSeconds(start), "-t", formatSeconds(duration),
+		"-i", input,
+		"-an", // no audio
+		"-filter_complex", filter,
+	}
+	args = append(args, output...)
+	args = append(args, "-") // print to stdout
+	out, _, err := c.RunFfmpeg(context.Background(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Check the time range is within the probed duration of the input.
+func (c *Client) checkRange(
+	input string, start, duration time.Duration) error {
+	if start < 0 || duration <= 0 {
+		return fmt.Errorf(
+			"%w: start %v, duration %v", errInvalidRange, start, duration)
+	}
+	info, err := c.Probe(input)
+	if err != nil {
+		return err
+	}
+	// a live stream has no duration
+	if info.Duration > 0 && start+duration > info.Duration {
+		return fmt.Errorf("%w: %v+%v exceeds %v",
+			errInvalidRange, start, duration, info.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata of a media file or stream.
+type MediaInfo struct {
+	// of the first video stream, 0 if none
+	Width  int
+	Height int
+	// of the first video stream, e.g. "h264"
+	CodecName string
+	Duration  time.Duration
+	// frames per second of the first video stream
+	FrameRate float64
+	// bits per second
+	BitRate int64
+	// clockwise degrees to display the first video stream upright, 0, 90,
+	// 180 or 270, see Rotate
+	Rotation int
+	Streams  []StreamInfo
+}
+
+// Metadata of a stream in the media.
+type StreamInfo struct {
+	Index int
+	// "video", "audio", "subtitle", ...
+	CodecType string
+	CodecName string
+	Width     int
+	Height    int
+	// whether a video stream carries embedded CEA-608/708 captions
+	ClosedCaptions bool
+}
+
+// Output of ffprobe -print_format json.
+type probeOutput struct {
+	Streams []struct {
+		Index        int    `json:"index"`
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		// 1 if the video carries closed captions
+		ClosedCaptions int `json:"closed_captions"`
+		// display matrix of newer ffprobe
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+		// rotate tag of older ffprobe
+		Tags struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Get the metadata of a media via ffprobe.
+//
+// Args:
+//
+//	input: url or local path of the media
+//
+// Returns:
+//
+//	*MediaInfo: the metadata
+//	error: error
+func Probe(input string) (*MediaInfo, error) {
+	return defaultClient.Probe(input)
+}
+
+// Like Probe, using the client's settings.
+func (c *Client) Probe(input string) (*MediaInfo, error) {
+	out, err := c.runFfprobe(context.Background(),
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		input,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var po probeOutput
+	if err := json.Unmarshal(out, &po); err != nil {
+		return nil, err
+	}
+	info := &MediaInfo{}
+	if sec, err := strconv.ParseFloat(po.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(sec * float64(time.Second))
+	}
+	info.BitRate, _ = strconv.ParseInt(po.Format.BitRate, 10, 64)
+	hasVideo := false
+	for _, s := range po.Streams {
+		info.Streams = append(info.Streams, StreamInfo{
+			Index:          s.Index,
+			CodecType:      s.CodecType,
+			CodecName:      s.CodecName,
+			Width:          s.Width,
+			Height:         s.Height,
+			ClosedCaptions: s.ClosedCaptions != 0,
+		})
+		if s.CodecType != "video" || hasVideo {
+			continue
+		}
+		// the first video stream
+		hasVideo = true
+		info.Width, info.Height = s.Width, s.Height
+		info.CodecName = s.CodecName
+		info.FrameRate = parseRational(s.AvgFrameRate)
+		if info.FrameRate == 0 {
+			info.FrameRate = parseRational(s.RFrameRate)
+		}
+		if deg, err := strconv.ParseFloat(s.Tags.Rotate, 64); err == nil {
+			info.Rotation = normalizeRotation(deg)
+		}
+		for _, sd := range s.SideDataList {
+			if sd.Rotation != 0 {
+				// counterclockwise in the display matrix
+				info.Rotation = normalizeRotation(-sd.Rotation)
+			}
+		}
+	}
+	return info, nil
+}
+
+// Output of ffprobe -show_entries frame=best_effort_timestamp_time.
+type probeFrames struct {
+	Frames []struct {
+		Timestamp string `json:"best_effort_timestamp_time"`
+	} `json:"frames"`
+}
+
+// Get the timestamps of the keyframes of the first video stream via
+// ffprobe, e.g. to schedule scans at content changes.
+//
+// Only keyframes are decoded, but the whole input is read, so it takes a
+// while for long videos.
+//
+// Args:
+//
+//	input: url or local path of the video
+//
+// Returns:
+//
+//	[]time.Duration: timestamps in order
+//	error: error
+func KeyframeTimestamps(input string) ([]time.Duration, error) {
+	return defaultClient.KeyframeTimestamps(input)
+}
+
+// Like KeyframeTimestamps, using the client's settings.
+func (c *Client) KeyframeTimestamps(input string) ([]time.Duration, error) {
+	out, err := c.runFfprobe(context.Background(),
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-skip_frame", "nokey", // only decode keyframes
+		"-show_entries", "frame=best_effort_timestamp_time",
+		"-print_format", "json",
+		input,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var pf probeFrames
+	if err := json.Unmarshal(out, &pf); err != nil {
+		return nil, err
+	}
+	var timestamps []time.Duration
+	for _, f := range pf.Frames {
+		// "N/A" for frames without a timestamp
+		sec, err := strconv.ParseFloat(f.Timestamp, 64)
+		if err != nil {
+			continue
+		}
+		timestamps = append(timestamps,
+			time.Duration(sec*float64(time.Second)))
+	}
+	return timestamps, nil
+}
+
+// Normalize degrees to the nearest of 0, 90, 180 and 270.
+func normalizeRotation(deg float64) int {
+	r := int(math.Round(deg/90)) * 90 % 360
+	if r < 0 {
+		r += 360
+	}
+	return r
+}
+
+// Parse a rational like "30000/1001", 0 if invalid.
+func parseRational(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// Run the managed FFprobe until ctx is done and return the stdout.
+func (c *Client) runFfprobe(
+	ctx context.Context, args ...string) ([]byte, error) {
+	ffprobe, err := c.Ffprobe()
+	if err != nil {
+		return nil, err
+	}
+	release, err := acquireProcess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, ffprobe, args...)
+	out, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, stderr
+	if err := cmd.Run(); err != nil {
+		return nil, newFfmpegError(err, stderr)
+	}
+	return out.Bytes(), nil
+}
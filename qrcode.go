@@ -1,17 +1,55 @@
 package ffmpeghelper
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/makiuchi-d/gozxing"
 	"github.com/makiuchi-d/gozxing/multi/qrcode"
+	_ "golang.org/x/image/webp"
 )
 
 var qrReader = qrcode.NewQRCodeMultiReader()
 
 func ImgScanQrcode(img image.Image) ([]string, error) {
-	bmp, _ := gozxing.NewBinaryBitmapFromImage(img)
-	results, err := qrReader.DecodeMultiple(bmp, nil)
+	return ScanQrcodeWithHints(img, nil)
+}
+
+// Scan QR codes in an image with gozxing decode hints.
+//
+// For example gozxing.DecodeHintType_TRY_HARDER: true improves the
+// detection on blurry frames at the cost of CPU, and
+// gozxing.DecodeHintType_CHARACTER_SET: "UTF-8" sets the charset.
+//
+// Args:
+//
+//	img: the image
+//	hints: decode hints, or nil for the default
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	error: error
+func ScanQrcodeWithHints(
+	img image.Image, hints map[gozxing.DecodeHintType]any) ([]string, error) {
+	return defaultClient.scanQrcodeTexts(img, hints)
+}
+
+// Scan QR codes in an image with the client's preprocessing.
+func (c *Client) scanQrcodeTexts(
+	img image.Image, hints map[gozxing.DecodeHintType]any) ([]string, error) {
+	results, _, err := c.scanQrcodes(img, hints)
 	if err != nil {
 		return nil, err
 	}
@@ -21,3 +59,488 @@ func ImgScanQrcode(img image.Image) ([]string, error) {
 	}
 	return data, nil
 }
+
+// Scan QR codes with the client's preprocessing, returning the factor
+// mapping the result points back to img.
+func (c *Client) scanQrcodes(
+	img image.Image, hints map[gozxing.DecodeHintType]any,
+) ([]*gozxing.Result, float64, error) {
+	bmp, scale, err := newBitmap(img, c.getScanMaxDimension())
+	if err != nil {
+		return nil, 0, err
+	}
+	results, err := qrReader.DecodeMultiple(bmp, hints)
+	return results, scale, err
+}
+
+// Scan QR codes in an encoded jpeg, png, gif or webp image.
+//
+// Args:
+//
+//	data: the encoded image
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	error: error
+func ScanQrcodeBytes(data []byte) ([]string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return ImgScanQrcode(img)
+}
+
+// Scan QR codes in every frame of an animated gif, e.g. a rolling code.
+//
+// Frames are composited like a player shows them, so partial frames of an
+// optimized gif are scanned in full.
+//
+// Args:
+//
+//	data: the encoded gif
+//
+// Returns:
+//
+//	[]string: unique decoded texts in order of appearance
+//	error: error
+func ScanGifQrcode(data []byte) ([]string, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	seen := map[string]bool{}
+	var texts []string
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		found, _ := ImgScanQrcode(canvas)
+		for _, text := range found {
+			if !seen[text] {
+				seen[text] = true
+				texts = append(texts, text)
+			}
+		}
+		// dispose before the next frame
+		switch {
+		case previous != nil:
+			canvas = previous
+		case i < len(g.Disposal) && g.Disposal[i] == gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent,
+				image.Point{}, draw.Src)
+		}
+	}
+	return texts, nil
+}
+
+// Decoded QR code with its location.
+type QRResult struct {
+	Text string
+	// corners and finder patterns in the image
+	Points []image.Point
+	Format gozxing.BarcodeFormat
+}
+
+// Convert a gozxing result, with the points multiplied by scale.
+func newQRResult(r *gozxing.Result, scale float64) QRResult {
+	points := make([]image.Point, 0, len(r.GetResultPoints()))
+	for _, p := range r.GetResultPoints() {
+		points = append(points, image.Pt(
+			int(math.Round(p.GetX()*scale)), int(math.Round(p.GetY()*scale))))
+	}
+	return QRResult{
+		Text:   r.GetText(),
+		Points: points,
+		Format: r.GetBarcodeFormat(),
+	}
+}
+
+// Scan QR codes in an image with their locations.
+//
+// Args:
+//
+//	img: the image
+//
+// Returns:
+//
+//	[]QRResult: decoded codes
+//	error: error
+func ScanQrcodeDetailed(img image.Image) ([]QRResult, error) {
+	results, scale, err := defaultClient.scanQrcodes(img, nil)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]QRResult, 0, len(results))
+	for _, r := range results {
+		data = append(data, newQRResult(r, scale))
+	}
+	return data, nil
+}
+
+// Scan QR codes in an image, telling if they are mirrored, e.g. by a
+// misconfigured camera.
+//
+// gozxing reads most mirrored codes as is, the image flipped horizontally
+// is scanned if none is found.
+//
+// Args:
+//
+//	img: the image
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	bool: whether the first code is mirrored in img
+//	error: error of the flipped scan if neither finds a code, or of the
+//	first scan if it fails otherwise
+func ScanQrcodeMirrored(img image.Image) ([]string, bool, error) {
+	flipped := false
+	results, err := ScanQrcodeDetailed(img)
+	var notFound gozxing.NotFoundException
+	if err != nil && !errors.As(err, &notFound) {
+		// flipping doesn't help with other errors
+		return nil, false, err
+	}
+	if err != nil || len(results) == 0 {
+		flipped = true
+		if results, err = ScanQrcodeDetailed(FlipHorizontal(img)); err != nil {
+			return nil, false, err
+		}
+	}
+	if len(results) == 0 {
+		return nil, false, nil
+	}
+	data := make([]string, 0, len(results))
+	for _, r := range results {
+		data = append(data, r.Text)
+	}
+	return data, isMirroredQR(results[0].Points) != flipped, nil
+}
+
+// Check if the finder patterns of a QR code, bottom left, top left and top
+// right, are counterclockwise in image coordinates, i.e. mirrored.
+func isMirroredQR(points []image.Point) bool {
+	if len(points) < 3 {
+		return false
+	}
+	bl, tl, tr := points[0], points[1], points[2]
+	cross := (tr.X-tl.X)*(bl.Y-tl.Y) - (tr.Y-tl.Y)*(bl.X-tl.X)
+	return cross < 0
+}
+
+// no QR code is decoded from the image
+var ErrNoQRCode = errors.New("no qr code found")
+
+// Scan the primary QR code of an image, the one with the largest bounding
+// box, or the closest to the center of equal ones.
+//
+// Args:
+//
+//	img: the image
+//
+// Returns:
+//
+//	string: decoded text
+//	error: ErrNoQRCode if none, or error
+func ScanPrimaryQrcode(img image.Image) (string, error) {
+	results, err := ScanQrcodeDetailed(img)
+	var notFound gozxing.NotFoundException
+	if errors.As(err, &notFound) {
+		return "", ErrNoQRCode
+	} else if err != nil {
+		return "", err
+	} else if len(results) == 0 {
+		return "", ErrNoQRCode
+	}
+	// the result points are relative to the bounds' min
+	center := img.Bounds().Size().Div(2)
+	best, bestArea, bestDist := "", -1, 0
+	for _, r := range results {
+		box := pointsBounds(r.Points)
+		area := box.Dx() * box.Dy()
+		d := box.Min.Add(box.Size().Div(2)).Sub(center)
+		dist := d.X*d.X + d.Y*d.Y
+		if area > bestArea || (area == bestArea && dist < bestDist) {
+			best, bestArea, bestDist = r.Text, area, dist
+		}
+	}
+	return best, nil
+}
+
+// Get the bounding box of the points.
+func pointsBounds(points []image.Point) image.Rectangle {
+	var box image.Rectangle
+	for i, p := range points {
+		if i == 0 {
+			box = image.Rectangle{p, p}
+			continue
+		}
+		box.Min.X, box.Min.Y = min(box.Min.X, p.X), min(box.Min.Y, p.Y)
+		box.Max.X, box.Max.Y = max(box.Max.X, p.X), max(box.Max.Y, p.Y)
+	}
+	return box
+}
+
+var errRegionEmpty = errors.New("region outside the image")
+
+// Scan QR codes in a region of an image, e.g. a corner where the code is
+// known to appear, which is faster and ignores other codes on screen.
+//
+// Args:
+//
+//	img: the image
+//	rect: region in the image's coordinates
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	error: error
+func ScanQrcodeRegion(img image.Image, rect image.Rectangle) ([]string, error) {
+	region, err := cropImage(img, rect)
+	if err != nil {
+		return nil, err
+	}
+	return ImgScanQrcode(region)
+}
+
+// Crop an image to the rect, sharing the pixels if the image supports it.
+func cropImage(img image.Image, rect image.Rectangle) (image.Image, error) {
+	rect = rect.Intersect(img.Bounds())
+	if rect.Empty() {
+		return nil, errRegionEmpty
+	}
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect), nil
+	}
+	out := image.NewRGBA(rect)
+	draw.Draw(out, rect, img, rect.Min, draw.Src)
+	return out, nil
+}
+
+// frames to try scanning from a stream
+const scanStreamFrames = 5
+
+// Scan QR codes in a H.264 M3U8 stream.
+//
+// Up to 5 frames are scanned until one decodes at least one code.
+//
+// Args:
+//
+//	url: url of the stream
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	error: error
+func ScanStreamQrcode(url string) ([]string, error) {
+	return defaultClient.ScanStreamQrcode(url)
+}
+
+// Like ScanStreamQrcode, using the client's settings.
+func (c *Client) ScanStreamQrcode(url string) ([]string, error) {
+	imgs, err := c.H264M3U8GetImages(url, scanStreamFrames)
+	if err != nil {
+		return nil, err
+	}
+	return c.scanFirstQrcodes(imgs)
+}
+
+// Scan the images in order until one decodes at least one code.
+func (c *Client) scanFirstQrcodes(imgs []image.Image) ([]string, error) {
+	var lastErr error
+	for _, img := range imgs {
+		data, err := c.scanQrcodeTexts(img, nil)
+		if err == nil && len(data) > 0 {
+			return data, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = gozxing.NewNotFoundException()
+	}
+	return nil, lastErr
+}
+
+// Scan QR codes in the first frame of a .ts segment already in memory, e.g.
+// received over a non-HTTP transport.
+//
+// Args:
+//
+//	ts: data of the segment
+//
+// Returns:
+//
+//	[]string: decoded texts
+//	error: error, wrapping ErrTsReadFailed if no frame can be read
+func ScanTsQrcode(ts []byte) ([]string, error) {
+	return defaultClient.ScanTsQrcode(ts)
+}
+
+// Like ScanTsQrcode, using the client's settings.
+func (c *Client) ScanTsQrcode(ts []byte) ([]string, error) {
+	if len(ts) == 0 {
+		return nil, ErrTsReadFailed
+	}
+	// resolve first so a missing ffmpeg isn't reported as a bad segment
+	if _, err := c.Ffmpeg(); err != nil {
+		return nil, err
+	}
+	img, err := c.ReaderGetImage(bytes.NewReader(ts))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTsReadFailed, err)
+	}
+	return c.scanQrcodeTexts(img, nil)
+}
+
+// max frames scanned from a video
+const scanVideoMaxFrames = 300
+
+// Scan QR codes in every n-th frame of a video, e.g. rolling codes.
+//
+// At most 300 frames are scanned to bound the CPU on long videos.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	everyN: scan every n-th frame
+//
+// Returns:
+//
+//	[]string: unique decoded texts in order of appearance
+//	error: error
+func ScanVideoQrcodes(input string, everyN int) ([]string, error) {
+	return defaultClient.ScanVideoQrcodes(input, everyN)
+}
+
+// Like ScanVideoQrcodes, using the client's settings.
+func (c *Client) ScanVideoQrcodes(input string, everyN int) ([]string, error) {
+	frames, _, err := c.videoScanFrames(input, everyN, false)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var data []string
+	// the jpegs are buffered, decode one image at a time to not hold them
+	// all decoded
+	for _, frame := range frames {
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return nil, err
+		}
+		texts, _ := c.scanQrcodeTexts(img, nil)
+		for _, text := range texts {
+			if !seen[text] {
+				seen[text] = true
+				data = append(data, text)
+			}
+		}
+	}
+	return data, nil
+}
+
+// QR code decoded from a frame of a video.
+type TimedQR struct {
+	Text string
+	// timestamp of the frame
+	Timestamp time.Duration
+}
+
+// Scan QR codes in every n-th frame of a video with the timestamps they
+// appear, e.g. to reconstruct a timeline of rolling codes.
+//
+// A code is reported each time it appears in a scanned frame without being
+// in the previous one. At most 300 frames are scanned like
+// ScanVideoQrcodes.
+//
+// Args:
+//
+//	input: url or local path of the video
+//	everyN: scan every n-th frame
+//
+// Returns:
+//
+//	[]TimedQR: decoded codes in order of appearance
+//	error: error
+func ScanVideoQrcodesTimed(input string, everyN int) ([]TimedQR, error) {
+	return defaultClient.ScanVideoQrcodesTimed(input, everyN)
+}
+
+// Like ScanVideoQrcodesTimed, using the client's settings.
+func (c *Client) ScanVideoQrcodesTimed(
+	input string, everyN int) ([]TimedQR, error) {
+	frames, timestamps, err := c.videoScanFrames(input, everyN, true)
+	if err != nil {
+		return nil, err
+	}
+	var data []TimedQR
+	prev := map[string]bool{}
+	for i, frame := range frames {
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return nil, err
+		}
+		texts, _ := c.scanQrcodeTexts(img, nil)
+		cur := map[string]bool{}
+		for _, text := range texts {
+			if !prev[text] && !cur[text] {
+				data = append(data, TimedQR{text, timestamps[i]})
+			}
+			cur[text] = true
+		}
+		prev = cur
+	}
+	return data, nil
+}
+
+// pts_time of a frame logged by the showinfo filter
+var showinfoTimeRe = regexp.MustCompile(
+	`Parsed_showinfo.* n: *\d+ .*pts_time:(-?[0-9.]+)`)
+
+// Get every n-th frame of a video as jpegs, with their timestamps if
+// timed.
+func (c *Client) videoScanFrames(input string,
+	everyN int, timed bool) ([][]byte, []time.Duration, error) {
+	everyN = max(everyN, 1)
+	level := c.getFfmpegLogLevel() // see SetFfmpegLogLevel
+	filter := fmt.Sprintf("select=not(mod(n\\,%d))", everyN)
+	if timed {
+		// the timestamps are logged at the info level
+		level = "info"
+		filter += ",showinfo"
+	}
+	out, stderr, err := c.RunFfmpeg(context.Background(),
+		"-v", level,
+		"-hide_banner", "-nostats",
+		"-i", input,
+		"-an", // no audio
+		"-vf", filter,
+		"-vsync", "vfr", // drop the unselected frames
+		"-vframes", strconv.Itoa(scanVideoMaxFrames),
+		"-f", "image2pipe", "-c:v", "mjpeg", // output as jpegs
+		"-", // print to stdout
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	frames := splitJpegs(out)
+	if !timed {
+		return frames, nil, nil
+	}
+	var timestamps []time.Duration
+	for _, m := range showinfoTimeRe.FindAllSubmatch(stderr, -1) {
+		timestamps = append(timestamps, parseSeconds(string(m[1])))
+	}
+	if len(timestamps) < len(frames) {
+		return nil, nil, fmt.Errorf(
+			"got %d timestamps of %d frames", len(timestamps), len(frames))
+	}
+	return frames, timestamps, nil
+}
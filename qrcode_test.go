@@ -0,0 +1,46 @@
+package ffmpeghelper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// Draw a QR code of text with its top left corner at p.
+func drawQrcode(t *testing.T, img draw.Image, text string, p image.Point) {
+	t.Helper()
+	m, err := qrcode.NewQRCodeWriter().Encode(
+		text, gozxing.BarcodeFormat_QR_CODE, 120, 120, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < m.GetHeight(); y++ {
+		for x := 0; x < m.GetWidth(); x++ {
+			if m.Get(x, y) {
+				img.Set(p.X+x, p.Y+y, color.Black)
+			}
+		}
+	}
+}
+
+func TestScanPrimaryQrcodeOffset(t *testing.T) {
+	// bounds not starting at (0,0) like a cropped frame
+	bounds := image.Rect(200, 200, 1000, 600)
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+	// equal codes, the first at the center, the second near the center of
+	// the absolute bounds
+	drawQrcode(t, img, "center", bounds.Min.Add(image.Pt(340, 140)))
+	drawQrcode(t, img, "offset", bounds.Min.Add(image.Pt(590, 240)))
+	got, err := ScanPrimaryQrcode(img)
+	if err != nil {
+		t.Fatalf("ScanPrimaryQrcode err: %v", err)
+	}
+	if got != "center" {
+		t.Errorf("ScanPrimaryQrcode = %q, want %q", got, "center")
+	}
+}
@@ -0,0 +1,190 @@
+package ffmpeghelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Error of FFmpeg exiting with a nonzero code.
+type FfmpegError struct {
+	ExitCode int
+	// captured stderr
+	Stderr string
+	Err    error
+}
+
+// max length of the stderr tail in the error message
+const stderrTailLen = 512
+
+func (e *FfmpegError) Error() string {
+	msg := fmt.Sprintf("ffmpeg exited with code %d", e.ExitCode)
+	tail := strings.TrimSpace(e.Stderr)
+	if len(tail) > stderrTailLen {
+		tail = tail[len(tail)-stderrTailLen:]
+		// don't start in the middle of a multi-byte rune
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+		tail = "..." + tail
+	}
+	if tail != "" {
+		msg += ": " + tail
+	}
+	return msg
+}
+
+func (e *FfmpegError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap the error of running ffmpeg with its stderr if it exited abnormally.
+func newFfmpegError(err error, stderr *bytes.Buffer) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	return &FfmpegError{
+		ExitCode: exitErr.ExitCode(),
+		Stderr:   stderr.String(),
+		Err:      err,
+	}
+}
+
+const defaultFfmpegLogLevel = "error"
+
+// Set the -v log level of ffmpeg run by the helper's functions, e.g. to
+// debug a misbehaving stream with the stderr of *FfmpegError.
+//
+// The default of "error" is the level the helper has always used rather
+// than "quiet", so *FfmpegError carries the cause. It stays quiet as the
+// stderr of ffmpeg is captured, not printed.
+//
+// RunFfmpeg is left untouched and uses the given args only.
+//
+// Args:
+//
+//	level: "quiet", "error", "warning", "info" or the other ffmpeg
+//	levels, or "" to restore the default of "error"
+func SetFfmpegLogLevel(level string) {
+	defaultClient.set(WithFfmpegLogLevel(level))
+}
+
+func (c *Client) getFfmpegLogLevel() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.ffmpegLogLevel
+}
+
+// Set the -threads of ffmpeg extracting frames, e.g. 1 to avoid
+// scheduling spikes of one-frame decodes on a constrained machine.
+//
+// RunFfmpeg is left untouched and uses the given args only.
+//
+// Args:
+//
+//	n: threads of decoding and encoding, or 0 to restore ffmpeg's default
+//	of picking by the number of cores
+func SetFfmpegThreads(n int) {
+	defaultClient.set(WithFfmpegThreads(n))
+}
+
+// Get the -threads args, nil for ffmpeg's default.
+func (c *Client) threadArgs() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.ffmpegThreads == 0 {
+		return nil
+	}
+	return []string{"-threads", strconv.Itoa(c.ffmpegThreads)}
+}
+
+// slots of concurrently running ffmpeg and ffprobe processes
+var processSlots = make(chan struct{}, runtime.NumCPU())
+
+// Set the max number of ffmpeg and ffprobe processes running at once across
+// the package, the calls beyond wait for a slot.
+//
+// Args:
+//
+//	n: the max processes, or 0 to restore the default of the CPU count
+func SetMaxConcurrent(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	// running processes release the slots of the old limit
+	processSlots = make(chan struct{}, n)
+}
+
+// Wait for a process slot until ctx is done, returning its release.
+func acquireProcess(ctx context.Context) (func(), error) {
+	settingsLock.RLock()
+	slots := processSlots
+	settingsLock.RUnlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Wait for a process slot like acquireProcess, for at most the timeout of
+// the call if > 0, which elapsing is reported as ErrFfmpegTimeout.
+func acquireProcessTimeout(
+	ctx context.Context, timeout time.Duration) (func(), error) {
+	wait, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	release, err := acquireProcess(wait)
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf(
+			"%w waiting for a process slot after %v", ErrFfmpegTimeout, timeout)
+	}
+	return release, err
+}
+
+// Run the managed FFmpeg with arbitrary args.
+//
+// Args:
+//
+//	ctx: context to kill the process
+//	args: args passed to ffmpeg
+//
+// Returns:
+//
+//	[]byte: captured stdout
+//	[]byte: captured stderr
+//	error: error, *FfmpegError on a nonzero exit code
+func RunFfmpeg(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	return defaultClient.RunFfmpeg(ctx, args...)
+}
+
+// Like RunFfmpeg, using the client's settings.
+func (c *Client) RunFfmpeg(
+	ctx context.Context, args ...string) ([]byte, []byte, error) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err := acquireProcess(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), stderr.Bytes(), newFfmpegError(err, stderr)
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
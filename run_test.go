@@ -0,0 +1,20 @@
+package ffmpeghelper
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFfmpegErrorTail(t *testing.T) {
+	// a 3-byte rune straddling the cut
+	stderr := strings.Repeat("a", 100) + "€" +
+		strings.Repeat("b", stderrTailLen-2)
+	msg := (&FfmpegError{ExitCode: 1, Stderr: stderr}).Error()
+	if !utf8.ValidString(msg) {
+		t.Errorf("Error() = %q, not valid utf-8", msg)
+	}
+	if want := "...b"; !strings.Contains(msg, want) {
+		t.Errorf("Error() = %q, want it to contain %q", msg, want)
+	}
+}
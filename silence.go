@@ -0,0 +1,104 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Silent part of the audio.
+type SilenceInterval struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+var (
+	silenceStartRe = regexp.MustCompile(`silence_start: *(-?[0-9.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end: *(-?[0-9.]+)`)
+	lineSepRe      = regexp.MustCompile(`[\r\n]+`)
+	// progress of the stats line
+	statsTimeRe = regexp.MustCompile(`time=(\d+):(\d+):(\d+(?:\.\d+)?)`)
+)
+
+// Detect silent gaps in the first audio stream of a media, e.g. to alert on
+// a dead stream.
+//
+// The whole input is decoded by the silencedetect filter, so a live stream
+// must be bounded, e.g. by Trim first. Audio silent throughout gives one
+// interval spanning the whole duration.
+//
+// Args:
+//
+//	input: url or local path of the media
+//	noiseDB: max volume of silence in dB, e.g. -50
+//	minDuration: min duration of a gap
+//
+// Returns:
+//
+//	[]SilenceInterval: the gaps in order
+//	error: error
+func DetectSilence(input string, noiseDB float64,
+	minDuration time.Duration) ([]SilenceInterval, error) {
+	return defaultClient.DetectSilence(input, noiseDB, minDuration)
+}
+
+// Like DetectSilence, using the client's settings.
+func (c *Client) DetectSilence(input string, noiseDB float64,
+	minDuration time.Duration) ([]SilenceInterval, error) {
+	filter := fmt.Sprintf("silencedetect=noise=%sdB:d=%s",
+		strconv.FormatFloat(noiseDB, 'f', -1, 64), formatSeconds(minDuration))
+	_, stderr, err := c.RunFfmpeg(context.Background(),
+		"-v", "info", // the gaps are logged at the info level
+		"-hide_banner",
+		"-i", input,
+		"-vn", // no video
+		"-af", filter,
+		"-f", "null", "-", // discard the output
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseSilence(string(stderr)), nil
+}
+
+// Parse the silencedetect log into intervals, closing a trailing gap at the
+// end of the processed audio.
+func parseSilence(log string) []SilenceInterval {
+	var intervals []SilenceInterval
+	var start time.Duration
+	open := false
+	for _, line := range lineSepRe.Split(log, -1) {
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			start, open = parseSeconds(m[1]), true
+		} else if m := silenceEndRe.FindStringSubmatch(line); m != nil && open {
+			intervals = append(intervals,
+				SilenceInterval{start, parseSeconds(m[1])})
+			open = false
+		}
+	}
+	// older ffmpeg doesn't log the end of a gap running to the end
+	if open {
+		var end time.Duration
+		if all := statsTimeRe.FindAllStringSubmatch(log, -1); all != nil {
+			last := all[len(all)-1]
+			h, _ := strconv.Atoi(last[1])
+			m, _ := strconv.Atoi(last[2])
+			end = time.Duration(h)*time.Hour +
+				time.Duration(m)*time.Minute + parseSeconds(last[3])
+		}
+		intervals = append(intervals, SilenceInterval{start, max(end, start)})
+	}
+	return intervals
+}
+
+// Parse seconds like "1.5", 0 if invalid.
+func parseSeconds(s string) time.Duration {
+	sec, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	// negative for the first gap due to the filter's window
+	return max(time.Duration(sec*float64(time.Second)), 0)
+}
@@ -0,0 +1,42 @@
+package ffmpeghelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSilence(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		log  string
+		want []SilenceInterval
+	}{
+		{"pairs", "[silencedetect @ 0x55d0c1a4e2c0] silence_start: 1.5\n" +
+			"[silencedetect @ 0x55d0c1a4e2c0] silence_end: 3.25 | " +
+			"silence_duration: 1.75\n" +
+			"[silencedetect @ 0x55d0c1a4e2c0] silence_start: 7\n" +
+			"[silencedetect @ 0x55d0c1a4e2c0] silence_end: 9.5 | " +
+			"silence_duration: 2.5\n",
+			[]SilenceInterval{
+				{1500 * time.Millisecond, 3250 * time.Millisecond},
+				{7 * time.Second, 9500 * time.Millisecond},
+			}},
+		// the window of the filter puts the first start before 0
+		{"negative start", "[silencedetect @ 0x1] silence_start: -0.0213\r" +
+			"[silencedetect @ 0x1] silence_end: 2 | silence_duration: 2.02\r",
+			[]SilenceInterval{{0, 2 * time.Second}}},
+		// closed at the last stats time
+		{"trailing", "[silencedetect @ 0x1] silence_start: 4\n" +
+			"size=N/A time=00:00:05.00 bitrate=N/A speed= 500x\r" +
+			"size=N/A time=00:01:02.50 bitrate=N/A speed= 510x\n",
+			[]SilenceInterval{{4 * time.Second, 62500 * time.Millisecond}}},
+		{"trailing without stats", "[silencedetect @ 0x1] silence_start: 4\n",
+			[]SilenceInterval{{4 * time.Second, 4 * time.Second}}},
+		{"none", "size=N/A time=00:00:10.00 bitrate=N/A speed= 500x\n", nil},
+	} {
+		if got := parseSilence(tc.log); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseSilence(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
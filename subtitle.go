@@ -0,0 +1,79 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// the media has neither a subtitle stream nor closed captions
+var ErrNoSubtitleStream = errors.New("no subtitle stream")
+
+// Get the ffmpeg output args of a subtitle format.
+func subtitleOutputArgs(format string) ([]string, error) {
+	switch format {
+	case "srt":
+		return []string{"-c:s", "srt", "-f", "srt"}, nil
+	case "vtt":
+		return []string{"-c:s", "webvtt", "-f", "webvtt"}, nil
+	}
+	return nil, fmt.Errorf("unknown subtitle format: %q", format)
+}
+
+// Extract the first subtitle stream of a media as text.
+//
+// Without a subtitle stream, the CEA-608/708 closed captions embedded in
+// the video are extracted instead. Bitmap subtitles like DVB or PGS can't
+// be converted to text.
+//
+// Args:
+//
+//	input: url or local path of the media
+//	format: "srt" or "vtt"
+//
+// Returns:
+//
+//	[]byte: the subtitles
+//	error: ErrNoSubtitleStream if none, or error
+func ExtractSubtitles(input string, format string) ([]byte, error) {
+	return defaultClient.ExtractSubtitles(input, format)
+}
+
+// Like ExtractSubtitles, using the client's settings.
+func (c *Client) ExtractSubtitles(input string, format string) ([]byte, error) {
+	output, err := subtitleOutputArgs(format)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Probe(input)
+	if err != nil {
+		return nil, err
+	}
+	hasSubtitle, hasCaptions := false, false
+	for _, s := range info.Streams {
+		hasSubtitle = hasSubtitle || s.CodecType == "subtitle"
+		hasCaptions = hasCaptions || s.ClosedCaptions
+	}
+	// see SetFfmpegLogLevel
+	args := []string{"-v", c.getFfmpegLogLevel()}
+	switch {
+	case hasSubtitle:
+		args = append(args, "-i", input, "-map", "0:s:0")
+	case hasCaptions:
+		// the captions are side data of the video, exposed as a subtitle
+		// stream by the movie source
+		args = append(args,
+			"-f", "lavfi",
+			"-i", "movie="+escapeFilterValue(input)+"[out+subcc]",
+			"-map", "0:s:0")
+	default:
+		return nil, ErrNoSubtitleStream
+	}
+	args = append(args, output...)
+	args = append(args, "-") // print to stdout
+	out, _, err := c.RunFfmpeg(context.Background(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
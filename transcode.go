@@ -0,0 +1,134 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Options of transcoding, the zero value gets a H.264/AAC fragmented mp4.
+type TranscodeOptions struct {
+	// encoder of the video, "libx264" if empty, "copy" to keep the stream
+	VideoCodec string
+	// encoder of the audio, "aac" if empty, "copy" to keep the stream
+	AudioCodec string
+	// constant rate factor of the video, 0 for the encoder's default,
+	// ignored if VideoBitrate is set
+	CRF int
+	// bits per second, 0 for the encoder's default
+	VideoBitrate int
+	AudioBitrate int
+	// scale to the size, 0 keeps the source size and -1 on either side
+	// preserves the aspect ratio
+	Width  int
+	Height int
+	// ffmpeg muxer name like "mp4", "matroska", "webm" or "mpegts", "mp4"
+	// if empty
+	Container string
+	// move the mp4 index to the front for progressive playback, which needs
+	// a temp file as the muxer seeks back
+	FastStart bool
+}
+
+func (o *TranscodeOptions) container() string {
+	if o.Container == "" {
+		return "mp4"
+	}
+	return o.Container
+}
+
+// Build the ffmpeg codec args of the options.
+func (o *TranscodeOptions) codecArgs() []string {
+	vcodec, acodec := o.VideoCodec, o.AudioCodec
+	if vcodec == "" {
+		vcodec = "libx264"
+	}
+	if acodec == "" {
+		acodec = "aac"
+	}
+	args := []string{"-c:v", vcodec}
+	if vcodec != "copy" {
+		if o.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(o.VideoBitrate))
+		} else if o.CRF > 0 {
+			args = append(args, "-crf", strconv.Itoa(o.CRF))
+		}
+		if o.Width != 0 || o.Height != 0 {
+			args = append(args, "-vf", fmt.Sprintf("scale=%d:%d",
+				scaleSide(o.Width), scaleSide(o.Height)))
+		}
+	}
+	args = append(args, "-c:a", acodec)
+	if acodec != "copy" && o.AudioBitrate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(o.AudioBitrate))
+	}
+	return args
+}
+
+// Transcode a media with the options.
+//
+// The output is streamed through stdout, except for a mp4 or mov with
+// FastStart, which is written to a temp file first.
+//
+// Args:
+//
+//	input: url or local path of the media
+//	opts: options of the output
+//
+// Returns:
+//
+//	[]byte: the transcoded media
+//	error: error
+func Transcode(input string, opts TranscodeOptions) ([]byte, error) {
+	return defaultClient.Transcode(input, opts)
+}
+
+// Like Transcode, using the client's settings.
+func (c *Client) Transcode(
+	input string, opts TranscodeOptions) ([]byte, error) {
+	args := []string{
+		"-v", c.getFfmpegLogLevel(), // see SetFfmpegLogLevel
+		"-i", input,
+	}
+	args = append(args, opts.codecArgs()...)
+	container := opts.container()
+	isMp4 := container == "mp4" || container == "mov"
+	if isMp4 && opts.FastStart {
+		return c.transcodeToFile(args, container)
+	}
+	if isMp4 {
+		// mp4 needs seeking to write the index unless fragmented
+		args = append(args, "-movflags", "frag_keyframe+empty_moov")
+	}
+	args = append(args,
+		"-f", container,
+		"-", // print to stdout
+	)
+	out, _, err := c.RunFfmpeg(context.Background(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Transcode to a seekable temp file and read it back.
+func (c *Client) transcodeToFile(
+	args []string, container string) ([]byte, error) {
+	file, err := os.CreateTemp("", "ffmpeghelper-*."+container)
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+	args = append(args,
+		"-movflags", "+faststart",
+		"-f", container,
+		"-y", path, // overwrite the empty temp file
+	)
+	if _, _, err := c.RunFfmpeg(context.Background(), args...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
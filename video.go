@@ -2,12 +2,23 @@ package ffmpeghelper
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
+	"image/png"
 	"io"
+	"net/http"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/image/webp"
 )
 
 var (
@@ -16,27 +27,46 @@ var (
 	ErrTsReadFailed  = errors.New("failed to get ts data")
 )
 
-// Get .ts url from m3u8 url
-func m3u8GetTsUrl(url string) (string, error) {
-	res, err := httpClient.Get(url)
+// Send a GET request with the extra header for reading streams.
+func (c *Client) streamGet(
+	ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return "", ErrTsFetchFailed
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.getHTTPClient().Do(req)
+}
+
+// Get the .ts segment picked from m3u8 url, with its uris resolved
+func (c *Client) m3u8GetTs(
+	ctx context.Context, url string, header http.Header,
+	pick func(segments []segment) (int, error),
+) (*segment, error) {
+	pl, url, err := c.fetchMediaPlaylist(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if len(pl.segments) == 0 {
+		return nil, ErrTsParseFailed
+	}
+	i, err := pick(pl.segments)
+	if err != nil {
+		return nil, err
 	}
-	body, _ := io.ReadAll(res.Body)
-	// parse the last .ts url
-	if parts := strings.Split(
-		strings.TrimSuffix(string(body), "\r\n"), "\r\n"); len(parts) > 0 {
-		ts := parts[len(parts)-1]
-		return url[:strings.LastIndex(url, "/")+1] + ts, nil
+	seg := pl.segments[i]
+	if err := seg.resolve(url); err != nil {
+		return nil, err
 	}
-	return "", ErrTsParseFailed
+	return &seg, nil
 }
 
-// Get a jpeg image from a H.264 M3U8 stream.
+// Get a jpeg image from a M3U8 stream.
+//
+// The codec, e.g. H.264, HEVC or AV1, is detected by ffmpeg. The image may
+// be a cached one, see SetFrameCache.
 //
 // Args:
 //
@@ -46,44 +76,1066 @@ func m3u8GetTsUrl(url string) (string, error) {
 //
 //	image.Image: the jpeg image
 //	error: error
+func M3U8GetImage(url string) (image.Image, error) {
+	return defaultClient.M3U8GetImage(url)
+}
+
+// Like M3U8GetImage, using the client's settings.
+func (c *Client) M3U8GetImage(url string) (image.Image, error) {
+	cache := c.getFrameCache()
+	if cache == nil {
+		return c.H264M3U8GetImageFormat(url, FormatJpeg)
+	}
+	key := frameCacheKey(url)
+	if img, ok := cache.get(key); ok {
+		return img, nil
+	}
+	img, err := c.H264M3U8GetImageFormat(url, FormatJpeg)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, img)
+	return img, nil
+}
+
+// Alias of M3U8GetImage, which isn't limited to H.264.
 func H264M3U8GetImage(url string) (image.Image, error) {
-	// get ffmpeg path
-	ffmpeg, err := Ffmpeg()
+	return M3U8GetImage(url)
+}
+
+// Alias of Client.M3U8GetImage, which isn't limited to H.264.
+func (c *Client) H264M3U8GetImage(url string) (image.Image, error) {
+	return c.M3U8GetImage(url)
+}
+
+// Encoding of extracted frames.
+type ImageFormat string
+
+const (
+	FormatJpeg ImageFormat = "jpeg"
+	// lossless
+	FormatPng ImageFormat = "png"
+	// requires ffmpeg built with libwebp
+	FormatWebp ImageFormat = "webp"
+	// 256 colors
+	FormatGif ImageFormat = "gif"
+)
+
+var errUnknownFormat = errors.New("unknown image format")
+
+// Get the ffmpeg output args of an image format.
+func (f ImageFormat) outputArgs() ([]string, error) {
+	switch f {
+	case FormatJpeg:
+		// let ffmpeg convert the source pixel format, e.g. yuv420p, nv12 or
+		// 10-bit, to one the mjpeg encoder supports
+		return []string{
+			"-f", "image2pipe", "-c:v", "mjpeg", // output as jpegs
+		}, nil
+	case FormatPng:
+		return []string{
+			"-pix_fmt", "rgb24",
+			"-f", "image2pipe", "-c:v", "png", // output as pngs
+		}, nil
+	case FormatWebp:
+		return []string{
+			"-pix_fmt", "yuv420p",
+			"-f", "webp", "-c:v", "libwebp", // output as webp
+		}, nil
+	case FormatGif:
+		return []string{"-f", "gif"}, nil // output as gif
+	}
+	return nil, fmt.Errorf("%w: %q", errUnknownFormat, string(f))
+}
+
+// Get the MIME type of the format.
+func (f ImageFormat) mimeType() string {
+	return "image/" + string(f)
+}
+
+// Decode an image of the format.
+func (f ImageFormat) decode(data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch f {
+	case FormatJpeg:
+		return jpeg.Decode(r)
+	case FormatPng:
+		return png.Decode(r)
+	case FormatWebp:
+		return webp.Decode(r)
+	case FormatGif:
+		return gif.Decode(r)
+	}
+	return nil, fmt.Errorf("%w: %q", errUnknownFormat, string(f))
+}
+
+// Get an image of the format from a H.264 M3U8 stream.
+//
+// Args:
+//
+//	url: url of the stream
+//	format: encoding of the image
+//
+// Returns:
+//
+//	image.Image: the image
+//	error: error
+func H264M3U8GetImageFormat(
+	url string, format ImageFormat) (image.Image, error) {
+	return defaultClient.H264M3U8GetImageFormat(url, format)
+}
+
+// Like H264M3U8GetImageFormat, using the client's settings.
+func (c *Client) H264M3U8GetImageFormat(
+	url string, format ImageFormat) (image.Image, error) {
+	return c.H264M3U8GetImageWithOptions(url, FrameOptions{Format: format})
+}
+
+// Get a jpeg image from a H.264 M3U8 stream sending extra headers.
+//
+// Args:
+//
+//	url: url of the stream
+//	header: headers like Referer or Cookie for the m3u8 and ts requests
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func H264M3U8GetImageWithHeaders(
+	url string, header http.Header) (image.Image, error) {
+	return defaultClient.H264M3U8GetImageWithHeaders(url, header)
+}
+
+// Like H264M3U8GetImageWithHeaders, using the client's settings.
+func (c *Client) H264M3U8GetImageWithHeaders(
+	url string, header http.Header) (image.Image, error) {
+	return c.H264M3U8GetImageWithOptions(url, FrameOptions{Header: header})
+}
+
+// Get a jpeg image from a H.264 M3U8 stream passing extra ffmpeg input
+// options, see FrameOptions.InputArgs.
+//
+// Args:
+//
+//	url: url of the stream
+//	extraInputArgs: ffmpeg input options inserted before -i, e.g.
+//	[]string{"-analyzeduration", "1000000"}
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func H264M3U8GetImageWithInputArgs(
+	url string, extraInputArgs []string) (image.Image, error) {
+	return defaultClient.H264M3U8GetImageWithInputArgs(url, extraInputArgs)
+}
+
+// Like H264M3U8GetImageWithInputArgs, using the client's settings.
+func (c *Client) H264M3U8GetImageWithInputArgs(
+	url string, extraInputArgs []string) (image.Image, error) {
+	return c.H264M3U8GetImageWithOptions(
+		url, FrameOptions{InputArgs: extraInputArgs})
+}
+
+// Options of frame extraction, the zero value gets a source size jpeg.
+type FrameOptions struct {
+	// encoding of the image, FormatJpeg if empty
+	Format ImageFormat
+	// headers like Referer or Cookie for the m3u8 and ts requests
+	Header http.Header
+	// scale to the size, 0 keeps the source size and -1 on either side
+	// preserves the aspect ratio
+	Width  int
+	Height int
+	// scale down to fit in a square of the size, 0 for no limit, which
+	// speeds up ImgScanQrcode on large streams
+	MaxDimension int
+	// kill ffmpeg if it runs longer, 0 for no limit, also bounding the
+	// wait for a process slot
+	Timeout time.Duration
+	// concatenate the last segments of the playlist to have a clean
+	// keyframe near a scene cut, 0 or 1 for only the last one
+	Segments int
+	// text burned into the image, e.g. a timestamp, "" for none, see
+	// SetFontFile
+	Text string
+	// corner of the text, TextTopLeft if empty
+	TextPosition TextPosition
+	// jpeg quality by ffmpeg's -q:v from 2, the best, to 31, the smallest,
+	// 0 for ffmpeg's default, ignored for the other formats
+	Quality int
+	// extra ffmpeg input options inserted before -i, which mustn't
+	// contain -i. The segments are fetched by the package and piped, so
+	// use Header for HTTP headers; demuxer options like -analyzeduration
+	// or -fflags apply
+	InputArgs []string
+}
+
+func (o *FrameOptions) format() ImageFormat {
+	if o.Format == "" {
+		return FormatJpeg
+	}
+	return o.Format
+}
+
+// Build the -vf filter of the options with the font of the text, "" if
+// none.
+func (o *FrameOptions) filter(fontFile string) string {
+	var filters []string
+	if o.Width != 0 || o.Height != 0 {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d",
+			scaleSide(o.Width), scaleSide(o.Height)))
+	}
+	if m := o.MaxDimension; m > 0 {
+		filters = append(filters, fmt.Sprintf(
+			"scale=w=min(iw\\,%d):h=min(ih\\,%d)"+
+				":force_original_aspect_ratio=decrease:force_divisible_by=2",
+			m, m))
+	}
+	if o.Text != "" {
+		// after scaling to keep the font size
+		filters = append(filters,
+			drawtextFilter(o.Text, o.TextPosition, fontFile))
+	}
+	return strings.Join(filters, ",")
+}
+
+// Map a side of the size to the scale filter, where 0 also keeps the source
+// size.
+func scaleSide(n int) int {
+	if n == -1 {
+		// preserve the aspect ratio with an even size for yuv420p
+		return -2
+	}
+	return n
+}
+
+// Get an image from a H.264 M3U8 stream with the options.
+//
+// Args:
+//
+//	url: url of the stream
+//	opts: options of the image
+//
+// Returns:
+//
+//	image.Image: the image
+//	error: error
+func H264M3U8GetImageWithOptions(
+	url string, opts FrameOptions) (image.Image, error) {
+	return defaultClient.H264M3U8GetImageWithOptions(url, opts)
+}
+
+// Like H264M3U8GetImageWithOptions, using the client's settings.
+func (c *Client) H264M3U8GetImageWithOptions(
+	url string, opts FrameOptions) (image.Image, error) {
+	return c.m3u8Image(context.Background(), url, opts)
+}
+
+// Get an image from a M3U8 stream with the options until ctx is done.
+func (c *Client) m3u8Image(ctx context.Context,
+	url string, opts FrameOptions) (image.Image, error) {
+	out, err := c.m3u8ImageBytes(ctx, url, opts)
 	if err != nil {
 		return nil, err
 	}
+	return opts.format().decode(out)
+}
+
+// Get an encoded jpeg image from a H.264 M3U8 stream without decoding,
+// e.g. to save or forward it as is.
+//
+// Args:
+//
+//	url: url of the stream
+//
+// Returns:
+//
+//	[]byte: the encoded image
+//	string: MIME type of the image, "image/jpeg"
+//	error: error
+func H264M3U8GetImageBytes(url string) ([]byte, string, error) {
+	return defaultClient.H264M3U8GetImageBytes(url)
+}
+
+// Like H264M3U8GetImageBytes, using the client's settings.
+func (c *Client) H264M3U8GetImageBytes(url string) ([]byte, string, error) {
+	return c.H264M3U8GetImageBytesQuality(url, 0)
+}
+
+// Get an encoded jpeg image of the quality from a H.264 M3U8 stream without
+// decoding, e.g. a small one for QR scanning or a fine one for archival.
+//
+// Args:
+//
+//	url: url of the stream
+//	quality: ffmpeg's -q:v from 2, the best, to 31, the smallest, or 0 for
+//	ffmpeg's default
+//
+// Returns:
+//
+//	[]byte: the encoded image
+//	string: MIME type of the image, "image/jpeg"
+//	error: error
+func H264M3U8GetImageBytesQuality(
+	url string, quality int) ([]byte, string, error) {
+	return defaultClient.H264M3U8GetImageBytesQuality(url, quality)
+}
+
+// Like H264M3U8GetImageBytesQuality, using the client's settings.
+func (c *Client) H264M3U8GetImageBytesQuality(
+	url string, quality int) ([]byte, string, error) {
+	opts := FrameOptions{Quality: quality}
+	out, err := c.m3u8ImageBytes(context.Background(), url, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, opts.format().mimeType(), nil
+}
+
+// Write a jpeg image from a H.264 M3U8 stream to w as ffmpeg encodes it,
+// e.g. to a http.ResponseWriter, without buffering or re-encoding it.
+//
+// Args:
+//
+//	url: url of the stream
+//	w: writer of the image
+//
+// Returns:
+//
+//	error: error of ffmpeg or of w
+func H264M3U8WriteImage(url string, w io.Writer) error {
+	return defaultClient.H264M3U8WriteImage(url, w)
+}
+
+// Like H264M3U8WriteImage, using the client's settings.
+func (c *Client) H264M3U8WriteImage(url string, w io.Writer) error {
+	args, err := c.frameArgs(1, FrameOptions{})
+	if err != nil {
+		return err
+	}
 	// get .ts url
-	tsUrl, err := m3u8GetTsUrl(url)
+	ctx, cancel := withTimeout(context.Background(), c.getStreamTimeout())
+	segs, err := c.m3u8GetLastTs(ctx, url, nil, 1)
+	cancel()
+	if err != nil {
+		return err
+	}
+	n, err := c.segmentsWriteFfmpeg(
+		context.Background(), w, segs, nil, args, 0)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNoFrame
+	}
+	return nil
+}
+
+// Get an encoded image from a M3U8 stream with the options until ctx is
+// done.
+func (c *Client) m3u8ImageBytes(ctx context.Context,
+	url string, opts FrameOptions) ([]byte, error) {
+	args, err := c.frameArgs(1, opts)
 	if err != nil {
 		return nil, err
 	}
-	// get .ts body
-	res, err := httpClient.Get(tsUrl)
+	out, err := c.m3u8RunFfmpeg(
+		ctx, url, opts.Header, args, opts.Timeout, opts.Segments)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return nil, ErrTsReadFailed
+	if len(out) == 0 {
+		return nil, errNoFrame
+	}
+	return out, nil
+}
+
+// Get a jpeg image from a video stream like .ts read from r.
+//
+// Args:
+//
+//	r: reader of the stream
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func ReaderGetImage(r io.Reader) (image.Image, error) {
+	return defaultClient.ReaderGetImage(r)
+}
+
+// Like ReaderGetImage, using the client's settings.
+func (c *Client) ReaderGetImage(r io.Reader) (image.Image, error) {
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.frameArgs(1, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := runFfmpegPipe(context.Background(), ffmpeg, r, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errNoFrame
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+var errNoFrame = errors.New("no frame decoded")
+
+// Get jpeg images of the first n frames from a H.264 M3U8 stream.
+//
+// Fewer images are returned if the stream is shorter than n frames.
+//
+// Args:
+//
+//	url: url of the stream
+//	n: max number of frames
+//
+// Returns:
+//
+//	[]image.Image: the jpeg images
+//	error: error
+func H264M3U8GetImages(url string, n int) ([]image.Image, error) {
+	return defaultClient.H264M3U8GetImages(url, n)
+}
+
+// Like H264M3U8GetImages, using the client's settings.
+func (c *Client) H264M3U8GetImages(url string, n int) ([]image.Image, error) {
+	args, err := c.frameArgs(n, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.m3u8RunFfmpeg(context.Background(), url, nil, args, 0, 1)
+	if err != nil {
+		return nil, err
 	}
-	cmd := exec.Command(
-		ffmpeg,
-		"-v", "quiet", // no logs
+	return decodeJpegs(out)
+}
+
+// Build the ffmpeg args to read a stream from stdin and print n frames
+// with the options to stdout.
+func (c *Client) frameArgs(n int, opts FrameOptions) ([]string, error) {
+	return c.inputFrameArgs(pipeInputArgs(), n, opts)
+}
+
+// Build the ffmpeg input args to read a stream from stdin.
+func pipeInputArgs() []string {
+	return []string{
 		"-flags", "low_delay", // low delay
 		"-fflags", "discardcorrupt+flush_packets", // low delay
 		"-probesize", "2048", // low delay
 		"-i", "pipe:", // read from stdin
-		"-an",                  // no audio
-		"-pix_fmt", "yuvj420p", // source video format
-		"-vframes", "1", // 1 frame
+	}
+}
+
+// Build the ffmpeg args to read the input and print n frames with the
+// options to stdout.
+func (c *Client) inputFrameArgs(
+	input []string, n int, opts FrameOptions) ([]string, error) {
+	output, err := opts.format().outputArgs()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInputArgs(opts.InputArgs); err != nil {
+		return nil, err
+	}
+	// see SetFfmpegLogLevel
+	args := []string{"-v", c.getFfmpegLogLevel()}
+	// decoding threads, see SetFfmpegThreads
+	threads := c.threadArgs()
+	args = append(args, threads...)
+	// the extra options right before the last -i, input may be followed by
+	// output options like -ss
+	i := len(input)
+	for i > 0 && input[i-1] != "-i" {
+		i--
+	}
+	i = max(i-1, 0)
+	args = append(args, input[:i]...)
+	args = append(args, opts.InputArgs...)
+	args = append(args, input[i:]...)
+	args = append(args,
+		"-an",                       // no audio
+		"-vframes", strconv.Itoa(n), // n frames
 		"-g", "1", // force all frames to be key frames
-		"-f", "image2", // output as jpeg
-		"-", // print to stdout
 	)
+	var fontFile string
+	if opts.Text != "" {
+		fontFile = c.getFontFile()
+	}
+	if filter := opts.filter(fontFile); filter != "" {
+		args = append(args, "-vf", filter)
+	}
+	// encoding threads
+	args = append(args, threads...)
+	args = append(args, output...)
+	if q := opts.Quality; q != 0 && opts.format() == FormatJpeg {
+		if q < 2 || q > 31 {
+			return nil, fmt.Errorf("invalid jpeg quality: %d", q)
+		}
+		args = append(args, "-q:v", strconv.Itoa(q))
+	}
+	return append(args, "-"), nil // print to stdout
+}
+
+var errInputArgsInvalid = errors.New("invalid extra input args")
+
+// Check the extra input args don't add another input.
+func checkInputArgs(args []string) error {
+	for _, arg := range args {
+		if arg == "-i" {
+			return fmt.Errorf("%w: -i is not allowed", errInputArgsInvalid)
+		}
+	}
+	return nil
+}
+
+var errTimestampOutOfRange = errors.New("timestamp exceeds the duration")
+
+// Get a jpeg image at the timestamp from a local video file.
+//
+// Args:
+//
+//	path: path of the video
+//	at: timestamp of the frame
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func FileGetImage(path string, at time.Duration) (image.Image, error) {
+	return defaultClient.FileGetImage(path, at)
+}
+
+// Like FileGetImage, using the client's settings.
+func (c *Client) FileGetImage(
+	path string, at time.Duration) (image.Image, error) {
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.inputFrameArgs([]string{
+		"-ss", formatSeconds(at), // seek before opening for speed
+		"-i", path,
+	}, 1, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := runFfmpegPipe(context.Background(), ffmpeg, nil, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	// ffmpeg prints nothing when seeking past the end
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: %v", errTimestampOutOfRange, at)
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+var errSchemeUnsupported = errors.New("unsupported stream scheme")
+
+// Get a jpeg image from a RTSP or RTMP stream like an IP camera.
+//
+// RTSP is read over TCP. Connecting and reading time out after the
+// stream timeout, see SetStreamTimeout.
+//
+// Args:
+//
+//	url: rtsp://, rtsps://, rtmp:// or rtmps:// url of the stream
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error
+func StreamGetImage(url string) (image.Image, error) {
+	return defaultClient.StreamGetImage(url)
+}
+
+// Like StreamGetImage, using the client's settings.
+func (c *Client) StreamGetImage(url string) (image.Image, error) {
+	input, err := c.streamInputArgs(url)
+	if err != nil {
+		return nil, err
+	}
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.inputFrameArgs(input, 1, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := runFfmpegPipe(context.Background(), ffmpeg, nil, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errNoFrame
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+// Build the ffmpeg input args to read a RTSP or RTMP stream.
+func (c *Client) streamInputArgs(url string) ([]string, error) {
+	scheme, _, _ := strings.Cut(url, "://")
+	var input []string
+	switch strings.ToLower(scheme) {
+	case "rtsp", "rtsps":
+		// udp drops packets behind nat and firewalls
+		input = []string{"-rtsp_transport", "tcp"}
+		if d := c.getStreamTimeout(); d > 0 {
+			// socket timeout in microseconds, named -stimeout before 5.0
+			// where -timeout is the listen timeout
+			option := "-timeout"
+			if major, ok := c.ffmpegMajor(); ok && major < 5 {
+				option = "-stimeout"
+			}
+			input = append(input, option, strconv.FormatInt(
+				d.Microseconds(), 10))
+		}
+	case "rtmp", "rtmps":
+		if d := c.getStreamTimeout(); d > 0 {
+			// read/write timeout in microseconds
+			input = append(input, "-rw_timeout", strconv.FormatInt(
+				d.Microseconds(), 10))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", errSchemeUnsupported, scheme)
+	}
+	return append(input, "-i", url), nil
+}
+
+// Get a jpeg image at the offset from a H.264 M3U8 VOD stream.
+//
+// The segment covering the offset is picked by summing the #EXTINF
+// durations.
+//
+// Args:
+//
+//	url: url of the stream
+//	offset: offset from the start of the playlist
+//
+// Returns:
+//
+//	image.Image: the jpeg image
+//	error: error, also if the offset exceeds the total duration
+func H264M3U8GetImageAt(url string, offset time.Duration) (image.Image, error) {
+	return defaultClient.H264M3U8GetImageAt(url, offset)
+}
+
+// Like H264M3U8GetImageAt, using the client's settings.
+func (c *Client) H264M3U8GetImageAt(
+	url string, offset time.Duration) (image.Image, error) {
+	var within time.Duration
+	ctx, cancel := withTimeout(context.Background(), c.getStreamTimeout())
+	seg, err := c.m3u8GetTs(ctx, url, nil, func(segments []segment) (int, error) {
+		i, w, err := segmentAt(segments, offset)
+		within = w
+		return i, err
+	})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	// seek within the segment after decoding
+	input := append(pipeInputArgs(), "-ss", formatSeconds(within))
+	args, err := c.inputFrameArgs(input, 1, FrameOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.segmentRunFfmpeg(context.Background(), seg, nil, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: %v", errTimestampOutOfRange, offset)
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+// Find the segment covering the offset and the offset within it.
+func segmentAt(
+	segments []segment, offset time.Duration) (int, time.Duration, error) {
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("%w: %v", errTimestampOutOfRange, offset)
+	}
+	var start time.Duration
+	for i, seg := range segments {
+		if offset < start+seg.duration {
+			return i, offset - start, nil
+		}
+		start += seg.duration
+	}
+	return 0, 0, fmt.Errorf(
+		"%w: %v of %v", errTimestampOutOfRange, offset, start)
+}
+
+// Format a duration as ffmpeg seconds, e.g. "12.345".
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// Run ffmpeg with the last n .ts segments of a m3u8 stream concatenated
+// and piped to stdin, killing it after the timeout if > 0.
+func (c *Client) m3u8RunFfmpeg(ctx context.Context, url string,
+	header http.Header, args []string, timeout time.Duration,
+	n int) ([]byte, error) {
+	// get .ts urls
+	fetchCtx, cancel := withTimeout(ctx, c.getStreamTimeout())
+	segs, err := c.m3u8GetLastTs(fetchCtx, url, header, n)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	return c.segmentsRunFfmpeg(ctx, segs, header, args, timeout)
+}
+
+// Get the last n .ts segments of m3u8 url in order, with their uris
+// resolved, fewer if the playlist is shorter.
+func (c *Client) m3u8GetLastTs(ctx context.Context,
+	url string, header http.Header, n int) ([]segment, error) {
+	pl, url, err := c.fetchMediaPlaylist(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if len(pl.segments) == 0 {
+		return nil, ErrTsParseFailed
+	}
+	n = min(max(n, 1), len(pl.segments))
+	segs := append([]segment(nil), pl.segments[len(pl.segments)-n:]...)
+	for i := range segs {
+		if err := segs[i].resolve(url); err != nil {
+			return nil, err
+		}
+	}
+	return segs, nil
+}
+
+// Run ffmpeg with the .ts segment piped to stdin.
+func (c *Client) segmentRunFfmpeg(ctx context.Context, seg *segment,
+	header http.Header, args []string, timeout time.Duration) ([]byte, error) {
+	return c.segmentsRunFfmpeg(ctx, []segment{*seg}, header, args, timeout)
+}
+
+// Run ffmpeg with the .ts segments concatenated and piped to stdin.
+//
+// The process slot is taken before fetching the segments, so they aren't
+// held open while waiting for it, until ctx is done or the timeout. ffmpeg
+// is killed when ctx is done too.
+func (c *Client) segmentsRunFfmpeg(ctx context.Context, segs []segment,
+	header http.Header, args []string, timeout time.Duration) ([]byte, error) {
+	// get ffmpeg path
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return nil, err
+	}
+	release, err := acquireProcessTimeout(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	// get .ts body
+	in, closeIn, err := c.segmentsInput(ctx, segs, header)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIn()
+	// keep what the fast path consumes for the retry
+	consumed := &bytes.Buffer{}
+	out, err := runFfmpegPipeTimeout(
+		ctx, ffmpeg, io.TeeReader(in, consumed), args, timeout)
+	if errors.Is(err, ErrFfmpegTimeout) || errors.Is(err, exec.ErrWaitDelay) ||
+		(err == nil && len(out) > 0) {
+		return out, err
+	}
+	// the tiny probesize may miss large headers, retry with ffmpeg's
+	// default probing over the same data
+	in = io.MultiReader(bytes.NewReader(consumed.Bytes()), in)
+	retryOut, retryErr := runFfmpegPipeTimeout(
+		ctx, ffmpeg, in, widenProbe(args), timeout)
+	if retryErr == nil && len(retryOut) > 0 {
+		return retryOut, nil
+	}
+	return out, err
+}
+
+// Run ffmpeg with the .ts segments concatenated and piped to stdin,
+// streaming the stdout to w.
+//
+// The retry with the default probing is only done if nothing has been
+// written to w yet.
+func (c *Client) segmentsWriteFfmpeg(ctx context.Context, w io.Writer,
+	segs []segment, header http.Header, args []string,
+	timeout time.Duration) (int64, error) {
+	// get ffmpeg path
+	ffmpeg, err := c.Ffmpeg()
+	if err != nil {
+		return 0, err
+	}
+	release, err := acquireProcessTimeout(ctx, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	// get .ts body
+	in, closeIn, err := c.segmentsInput(ctx, segs, header)
+	if err != nil {
+		return 0, err
+	}
+	defer closeIn()
+	// keep what the fast path consumes for the retry
+	consumed := &bytes.Buffer{}
+	n, err := runFfmpegPipeTo(
+		ctx, ffmpeg, io.TeeReader(in, consumed), w, args, timeout)
+	// w may have failed before taking a byte, retrying would fail too
+	var wErr *writeError
+	if errors.As(err, &wErr) {
+		return n, wErr.err
+	}
+	if n > 0 || errors.Is(err, ErrFfmpegTimeout) ||
+		errors.Is(err, exec.ErrWaitDelay) {
+		return n, err
+	}
+	in = io.MultiReader(bytes.NewReader(consumed.Bytes()), in)
+	retryN, retryErr := runFfmpegPipeTo(
+		ctx, ffmpeg, in, w, widenProbe(args), timeout)
+	if errors.As(retryErr, &wErr) {
+		return retryN, wErr.err
+	}
+	if retryN > 0 {
+		return retryN, retryErr
+	}
+	return n, err
+}
+
+// Get the reader of the .ts segments concatenated, with a func closing it.
+func (c *Client) segmentsInput(ctx context.Context,
+	segs []segment, header http.Header) (io.Reader, func(), error) {
+	if len(segs) == 1 {
+		ctx, cancel := withTimeout(ctx, c.getStreamTimeout())
+		body, err := c.openSegment(ctx, &segs[0], header)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		return body, func() {
+			body.Close()
+			cancel()
+		}, nil
+	}
+	// fetch all of them first, each within the stream timeout
+	data := &bytes.Buffer{}
+	for i := range segs {
+		if err := c.readSegment(ctx, &segs[i], header, data); err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, func() {}, nil
+}
+
+// Open the body of the .ts segment, decrypted if encrypted.
+func (c *Client) openSegment(ctx context.Context,
+	seg *segment, header http.Header) (io.ReadCloser, error) {
+	res, err := c.streamGet(ctx, seg.uri, header)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, ErrTsReadFailed
+	}
+	if seg.key == nil {
+		return res.Body, nil
+	}
+	// decrypt the whole segment before piping
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = c.decryptSegment(ctx, seg, data, header); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Read the body of the .ts segment into w within the stream timeout.
+func (c *Client) readSegment(ctx context.Context,
+	seg *segment, header http.Header, w io.Writer) error {
+	ctx, cancel := withTimeout(ctx, c.getStreamTimeout())
+	defer cancel()
+	body, err := c.openSegment(ctx, seg, header)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// probesize and analyzeduration of the retry, ffmpeg's defaults
+const (
+	fallbackProbesize       = "5000000"
+	fallbackAnalyzeDuration = "5000000"
+)
+
+// Replace the low delay probesize of the args for streams with large
+// headers.
+func widenProbe(args []string) []string {
+	widened := make([]string, 0, len(args)+2)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-probesize" && i+1 < len(args) {
+			widened = append(widened,
+				"-probesize", fallbackProbesize,
+				"-analyzeduration", fallbackAnalyzeDuration)
+			i++
+			continue
+		}
+		widened = append(widened, args[i])
+	}
+	return widened
+}
+
+// Run ffmpeg with in piped to stdin and return the stdout once a process
+// slot is free, waiting for the slot and killing ffmpeg when ctx is done or
+// after the timeout if > 0.
+func runFfmpegPipe(ctx context.Context, ffmpeg string, in io.Reader,
+	args []string, timeout time.Duration) ([]byte, error) {
+	release, err := acquireProcessTimeout(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return runFfmpegPipeTimeout(ctx, ffmpeg, in, args, timeout)
+}
+
+// Returned when ffmpeg is killed for exceeding the timeout of the call.
+var ErrFfmpegTimeout = errors.New("ffmpeg timed out")
+
+// Run ffmpeg with in piped to stdin and return the stdout, killing it when
+// ctx is done or after the timeout if > 0. The caller holds the process
+// slot.
+func runFfmpegPipeTimeout(ctx context.Context, ffmpeg string, in io.Reader,
+	args []string, timeout time.Duration) ([]byte, error) {
 	out := &bytes.Buffer{}
-	cmd.Stdin, cmd.Stdout, cmd.Stderr = res.Body, out, nil
-	if err := cmd.Run(); err != nil {
+	if _, err := runFfmpegPipeTo(
+		ctx, ffmpeg, in, out, args, timeout); err != nil {
 		return nil, err
 	}
-	return jpeg.Decode(out)
+	return out.Bytes(), nil
+}
+
+// Run ffmpeg with in piped to stdin and its stdout streamed to w, killing it
+// when ctx is done or after the timeout if > 0, and return the bytes
+// written. The caller holds the process slot.
+func runFfmpegPipeTo(ctx context.Context, ffmpeg string, in io.Reader,
+	w io.Writer, args []string, timeout time.Duration) (int64, error) {
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	out, stderr := &pipeWriter{w: w}, &bytes.Buffer{}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, stderr
+	// don't hang on a wedged stdin copy once the process exits
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			cmd.Process.Kill()
+		})
+		defer timer.Stop()
+	}
+	// reap the process, also when killed
+	err := cmd.Wait()
+	switch {
+	case out.err != nil:
+		// ffmpeg fails on the drained pipe too, the writer's error is the
+		// cause
+		return out.n, &writeError{out.err}
+	case err != nil && timedOut.Load():
+		return out.n, fmt.Errorf("%w after %v", ErrFfmpegTimeout, timeout)
+	case err != nil && ctx.Err() != nil:
+		return out.n, ctx.Err()
+	case err != nil:
+		return out.n, newFfmpegError(err, stderr)
+	}
+	return out.n, nil
+}
+
+// Error of the writer ffmpeg's stdout is streamed to, as opposed to an
+// error of ffmpeg.
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *writeError) Unwrap() error {
+	return e.err
+}
+
+// Writer counting the bytes written, which keeps accepting writes after
+// the first error so ffmpeg never blocks on a full pipe.
+type pipeWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *pipeWriter) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return len(b), nil
+	}
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	if err != nil {
+		p.err = err
+	}
+	return len(b), nil
+}
+
+// Decode the concatenated jpegs printed by image2pipe.
+func decodeJpegs(data []byte) ([]image.Image, error) {
+	var imgs []image.Image
+	for _, frame := range splitJpegs(data) {
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		if err != nil {
+			return nil, err
+		}
+		imgs = append(imgs, img)
+	}
+	if len(imgs) == 0 {
+		return nil, errNoFrame
+	}
+	return imgs, nil
+}
+
+// Split concatenated jpegs at the EOI markers, which the entropy-coded data
+// never contains as 0xff bytes are stuffed.
+func splitJpegs(data []byte) [][]byte {
+	eoi := []byte{0xff, 0xd9}
+	var frames [][]byte
+	for len(data) > 0 {
+		i := bytes.Index(data, eoi)
+		if i < 0 {
+			break
+		}
+		frames = append(frames, data[:i+len(eoi)])
+		data = data[i+len(eoi):]
+	}
+	return frames
 }
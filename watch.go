@@ -0,0 +1,114 @@
+package ffmpeghelper
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// Capture a jpeg image from a M3U8 stream periodically until ctx is done.
+//
+// A frame is captured right away and then every interval. Both channels
+// are closed once ctx is done, which also stops the capture in progress. An
+// error of a failed capture is dropped if the previous one hasn't been
+// received yet, so it's fine to only range over the images.
+//
+// Args:
+//
+//	ctx: context to stop watching
+//	url: url of the stream
+//	interval: time between captures
+//
+// Returns:
+//
+//	<-chan image.Image: the captured images
+//	<-chan error: errors of failed captures
+func WatchM3U8(ctx context.Context, url string,
+	interval time.Duration) (<-chan image.Image, <-chan error) {
+	return defaultClient.WatchM3U8(ctx, url, interval)
+}
+
+// Like WatchM3U8, using the client's settings.
+func (c *Client) WatchM3U8(ctx context.Context, url string,
+	interval time.Duration) (<-chan image.Image, <-chan error) {
+	imgs := make(chan image.Image)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(imgs)
+		defer close(errs)
+		if interval <= 0 {
+			errs <- fmt.Errorf("invalid interval: %v", interval)
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ctx.Err() == nil {
+			// a new ffmpeg per capture, a live playlist rolls anyway,
+			// bypassing the frame cache for a fresh frame
+			img, err := c.m3u8Image(ctx, url, FrameOptions{})
+			if ctx.Err() != nil {
+				return
+			} else if err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			} else {
+				select {
+				case imgs <- img:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return imgs, errs
+}
+
+// Wait until a M3U8 stream is live, i.e. its playlist has a segment, e.g.
+// before capturing a scheduled broadcast.
+//
+// Args:
+//
+//	ctx: context to stop waiting
+//	url: url of the stream
+//	poll: time between checks of the playlist
+//
+// Returns:
+//
+//	error: nil once live, or the error of ctx with the last check's
+func WaitForStream(ctx context.Context, url string, poll time.Duration) error {
+	return defaultClient.WaitForStream(ctx, url, poll)
+}
+
+// Like WaitForStream, using the client's settings.
+func (c *Client) WaitForStream(
+	ctx context.Context, url string, poll time.Duration) error {
+	if poll <= 0 {
+		return fmt.Errorf("invalid poll interval: %v", poll)
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		// e.g. 404 or an empty playlist before the stream starts
+		fetchCtx, cancel := withTimeout(ctx, c.getStreamTimeout())
+		pl, _, err := c.fetchMediaPlaylist(fetchCtx, url, nil)
+		cancel()
+		if err == nil && len(pl.segments) > 0 {
+			return nil
+		} else if err == nil {
+			err = ErrTsParseFailed
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+}